@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: shreadbox-admin <command> [flags]
+
+Commands:
+  keys create -name NAME [-max-size MB] [-max-expiry DURATION] [-max-downloads N]
+  keys list
+  keys revoke ID
+`
+
+func main() {
+	// Load .env file if it exists
+	godotenv.Load()
+
+	keysFile := os.Getenv("API_KEYS_FILE")
+	if keysFile == "" {
+		keysFile = "./apikeys.json"
+	}
+
+	if len(os.Args) < 3 || os.Args[1] != "keys" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	store, err := auth.NewKeyStore(keysFile)
+	if err != nil {
+		log.Fatalf("Failed to open key store: %v", err)
+	}
+
+	switch os.Args[2] {
+	case "create":
+		createKey(store, os.Args[3:])
+	case "list":
+		listKeys(store)
+	case "revoke":
+		if len(os.Args) != 4 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		if err := store.Revoke(os.Args[3]); err != nil {
+			log.Fatalf("Failed to revoke key: %v", err)
+		}
+		fmt.Printf("Revoked key %s\n", os.Args[3])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func createKey(store *auth.KeyStore, args []string) {
+	flags := flag.NewFlagSet("keys create", flag.ExitOnError)
+	name := flags.String("name", "", "name identifying the key holder")
+	maxSize := flags.Int64("max-size", 0, "maximum file size in MB (0 = server default)")
+	maxExpiry := flags.Duration("max-expiry", 0, "maximum expiry time (0 = unlimited)")
+	maxDownloads := flags.Int("max-downloads", 0, "maximum downloads per share (0 = unlimited)")
+	flags.Parse(args)
+
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+
+	secret, key, err := store.Create(*name, auth.Policy{
+		MaxFileSize:  *maxSize * 1024 * 1024,
+		MaxExpiry:    *maxExpiry,
+		MaxDownloads: *maxDownloads,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create key: %v", err)
+	}
+
+	fmt.Printf("Created key %s (%s)\n", key.ID, key.Name)
+	fmt.Printf("API key: %s\n", secret)
+	fmt.Println("Store it now, it cannot be shown again.")
+}
+
+func listKeys(store *auth.KeyStore) {
+	keys, err := store.List()
+	if err != nil {
+		log.Fatalf("Failed to list keys: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tMAX SIZE\tMAX EXPIRY\tMAX DOWNLOADS\tCREATED")
+	for _, key := range keys {
+		maxSize, maxExpiry, maxDownloads := "-", "-", "-"
+		if key.Policy.MaxFileSize > 0 {
+			maxSize = fmt.Sprintf("%dMB", key.Policy.MaxFileSize/(1024*1024))
+		}
+		if key.Policy.MaxExpiry > 0 {
+			maxExpiry = key.Policy.MaxExpiry.String()
+		}
+		if key.Policy.MaxDownloads > 0 {
+			maxDownloads = fmt.Sprint(key.Policy.MaxDownloads)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, maxSize, maxExpiry, maxDownloads, key.CreatedAt.Format(time.RFC3339))
+	}
+	w.Flush()
+}
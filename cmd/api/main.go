@@ -1,15 +1,32 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hardiksharma/shreadbox/config"
+	"github.com/hardiksharma/shreadbox/internal/auth"
 	"github.com/hardiksharma/shreadbox/internal/cleanup"
+	"github.com/hardiksharma/shreadbox/internal/contenttype"
+	"github.com/hardiksharma/shreadbox/internal/docs"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
 	"github.com/hardiksharma/shreadbox/internal/handlers"
+	"github.com/hardiksharma/shreadbox/internal/mail"
+	"github.com/hardiksharma/shreadbox/internal/middleware"
+	"github.com/hardiksharma/shreadbox/internal/quota"
+	"github.com/hardiksharma/shreadbox/internal/scan"
+	"github.com/hardiksharma/shreadbox/internal/server"
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
 	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/hardiksharma/shreadbox/internal/webhook"
+	"github.com/hardiksharma/shreadbox/web"
 	"github.com/joho/godotenv"
 )
 
@@ -31,34 +48,193 @@ func main() {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 
+	// Initialize quotas, released whenever a share is removed
+	quotaManager := quota.NewManager(quota.Limits{
+		ClientBytes:  cfg.ClientQuota,
+		ClientShares: cfg.ClientMaxShares,
+		Capacity:     cfg.StorageCapacity,
+	})
+	storageService.Subscribe(func(event storage.Event) {
+		if event.Type.Removed() {
+			quotaManager.Release(event.File.Owner, event.File.QuotaSize)
+		}
+	})
+
+	// Initialize webhooks, delivered in the background as shares are
+	// downloaded or destroyed. Global deliveries are signed, so they need
+	// a secret to be verifiable.
+	if len(cfg.WebhookURLs) > 0 && cfg.WebhookSecret == "" {
+		log.Fatalf("WEBHOOK_URLS is set without WEBHOOK_SECRET")
+	}
+	webhooks := webhook.NewDispatcher(webhook.Options{
+		GlobalURLs:     cfg.WebhookURLs,
+		Secret:         cfg.WebhookSecret,
+		MaxAttempts:    cfg.WebhookMaxAttempts,
+		DeadLetterPath: cfg.WebhookDeadLetterFile,
+		AllowPrivate:   cfg.WebhookAllowPrivate,
+	})
+	webhooks.Start(2)
+	storageService.Subscribe(webhooks.Listener())
+
+	// Initialize email notifications
+	var mailer *mail.Mailer
+	var notifier *mail.Notifier
+	if cfg.SMTPHost != "" {
+		mailer = mail.NewMailer(mail.Options{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+		mailer.Start()
+		notifier = mail.NewNotifier(mailer, cfg.BaseURL)
+		storageService.Subscribe(notifier.Listener())
+	}
+
+	// Initialize API keys
+	keyStore, err := auth.NewKeyStore(cfg.APIKeysFile)
+	if err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+
+	// Initialize share tokens
+	tokenGenerator, err := sharetoken.NewGenerator(cfg.TokenFormat, cfg.TokenWords)
+	if err != nil {
+		log.Fatalf("Invalid token format: %v", err)
+	}
+	storageService.SetTokenGenerator(tokenGenerator)
+
 	// Initialize cleanup service
-	cleanupService := cleanup.NewService(storageService, cfg.CleanupInterval)
-	cleanupService.Start()
-	defer cleanupService.Stop()
+	cleanupService := cleanup.NewService(storageService, cfg.CleanupInterval, cfg.ReconcileInterval)
+	cleanupService.Start(context.Background())
 
 	// Initialize handlers
-	handler := handlers.NewHandler(storageService)
+	scanner, err := newScanner(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize scanner: %v", err)
+	}
+	compression, err := encryption.ParseCompression(cfg.Compression)
+	if err != nil {
+		log.Fatalf("Invalid compression: %v", err)
+	}
+	handler := handlers.NewHandler(storageService, quotaManager, handlers.Options{
+		MaxFileSize: cfg.MaxFileSize,
+		TypePolicy:  contenttype.NewPolicy(cfg.AllowedTypes, cfg.DeniedTypes, cfg.AllowedExtensions, cfg.DeniedExtensions),
+		Scanner:     scanner,
+		ScanTimeout: cfg.ScanTimeout,
+		Mail:        notifier,
+		LinkSecret:  []byte(cfg.LinkSecret),
+		DedupSecret: dedupSecret(cfg),
+		Compression: compression,
+		BLAKE3:      cfg.DigestBLAKE3,
+		Tokens:      sharetoken.NewLimiter(cfg.TokenMaxFailures, cfg.TokenFailureWindow),
+		BaseURL:     cfg.BaseURL,
+	})
 
 	// Initialize router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(middleware.Logger(), gin.Recovery())
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	router.Use(middleware.SecurityHeaders(cfg.HSTSMaxAge))
+
+	// Templates and static files are embedded in the binary
+	templates, err := web.Templates()
+	if err != nil {
+		log.Fatalf("Failed to parse templates: %v", err)
+	}
+	router.SetHTMLTemplate(templates)
+	router.StaticFS("/static", web.Static())
 
-	// Load templates
-	router.LoadHTMLGlob("web/templates/*")
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Serve static files
-	router.Static("/static", "web/static")
+	// Initialize server
+	srv := server.New(":"+cfg.Port, router, cfg.ShutdownTimeout)
+	srv.SetDrainDelay(cfg.ShutdownDelay)
+	if cfg.TLSEnabled() {
+		reloader, err := server.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		tlsConfig, err := server.NewTLSConfig(reloader, cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		srv.EnableTLS(tlsConfig)
+		go reloader.Watch(ctx, cfg.TLSReloadInterval)
+	}
 
 	// Setup routes
-	setupRoutes(router, handler)
+	setupRoutes(router, handler, srv, keyStore, cfg)
+
+	var wg sync.WaitGroup
+
+	// Redirect plain HTTP to HTTPS
+	if cfg.TLSEnabled() && cfg.HTTPRedirectPort != "" {
+		redirect := server.New(":"+cfg.HTTPRedirectPort, server.RedirectHandler(cfg.Port), cfg.ShutdownTimeout)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Printf("Redirecting HTTP on port %s to HTTPS", cfg.HTTPRedirectPort)
+			if err := redirect.Run(ctx); err != nil {
+				log.Printf("Redirect server error: %v", err)
+			}
+		}()
+	}
 
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	log.Printf("Server starting on port %s (TLS: %t)", cfg.Port, cfg.TLSEnabled())
+	if err := srv.Run(ctx); err != nil {
+		log.Printf("Server error: %v", err)
 	}
+	stop()
+	wg.Wait()
+
+	// Shut down background work once no more requests are being served
+	cleanupService.Stop()
+	stopCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	webhooks.Stop(stopCtx)
+	if mailer != nil {
+		mailer.Stop(stopCtx)
+	}
+	cancel()
+	if err := storageService.RemoveStaging(); err != nil {
+		log.Printf("Failed to remove staging files: %v", err)
+	}
+	log.Println("Server stopped")
 }
 
-func setupRoutes(router *gin.Engine, handler *handlers.Handler) {
+// dedupSecret returns the secret for deduplicated uploads, or nil if disabled
+func dedupSecret(cfg *config.Config) []byte {
+	if cfg.DedupSecret == "" {
+		return nil
+	}
+	return []byte(cfg.DedupSecret)
+}
+
+// newScanner creates the configured malware scanner, or nil if disabled
+func newScanner(cfg *config.Config) (scan.Scanner, error) {
+	switch cfg.Scanner {
+	case "":
+		return nil, nil
+	case "clamd":
+		return scan.NewClamdScanner(cfg.ClamdAddress), nil
+	case "command":
+		return scan.NewCommandScanner(cfg.ScanCommand)
+	default:
+		return nil, fmt.Errorf("unknown scanner %q", cfg.Scanner)
+	}
+}
+
+func setupRoutes(router *gin.Engine, handler *handlers.Handler, srv *server.Server, keys *auth.KeyStore, cfg *config.Config) {
+	// Render errors as problem+json
+	router.Use(middleware.ErrorHandler(cfg.OpaqueErrors))
+	router.NoRoute(middleware.NotFound)
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -67,18 +243,75 @@ func setupRoutes(router *gin.Engine, handler *handlers.Handler) {
 		})
 	})
 
+	// Readiness check, fails once shutdown begins
+	router.GET("/ready", srv.ReadyHandler)
+
+	// API documentation
+	router.GET("/docs", docs.ViewerHandler)
+	router.GET("/openapi.json", docs.SpecHandler)
+
 	// API routes
 	api := router.Group("/api")
 	{
-		api.POST("/upload", handler.Upload)
-		api.GET("/download/:token", handler.Download)
-		api.GET("/status/:token", handler.Status)
+		api.POST("/upload", middleware.Authenticate(keys, cfg.AuthRequired), handler.Upload)
+		api.GET("/download/:token", middleware.TokenRoute, handler.Download)
+		api.GET("/status/:token", middleware.TokenRoute, middleware.Authenticate(keys, false), handler.Status)
+		api.GET("/qr/:token", middleware.TokenRoute, handler.QRCode)
+		api.GET("/preview/:token", middleware.TokenRoute, handler.Preview)
+		api.GET("/me/shares", middleware.Authenticate(keys, true), handler.MyShares)
+		api.DELETE("/me/shares/:id", middleware.Authenticate(keys, true), handler.RevokeShare)
+
+		// Shares uploaded without an API key are managed with the token
+		// returned by the upload
+		api.GET("/shares/:id", handler.ManagedShare)
+		api.DELETE("/shares/:id", handler.RevokeManagedShare)
+
+		// File requests collect uploads from people without an account
+		api.POST("/requests", middleware.Authenticate(keys, true), handler.CreateRequest)
+		api.GET("/requests/:id", handler.GetRequest)
+		api.POST("/requests/:id/upload", handler.UploadToRequest)
+		api.GET("/me/requests", middleware.Authenticate(keys, true), handler.MyRequests)
+		api.GET("/me/requests/:id/files", middleware.Authenticate(keys, true), handler.RequestFiles)
+		api.GET("/me/requests/:id/files/:file", middleware.Authenticate(keys, true), handler.DownloadRequestFile)
+		api.DELETE("/me/requests/:id", middleware.Authenticate(keys, true), handler.CloseRequest)
+	}
+
+	// Admin routes require a client certificate when mTLS is configured,
+	// or else the admin token. Source addresses prove nothing behind a
+	// reverse proxy, so without either the admin API is not served.
+	var adminAuth gin.HandlerFunc
+	switch {
+	case cfg.TLSEnabled() && cfg.TLSClientCAFile != "":
+		adminAuth = middleware.RequireClientCert()
+	case cfg.AdminToken != "":
+		adminAuth = middleware.RequireAdminToken(cfg.AdminToken)
+	default:
+		log.Println("Admin API disabled: set TLS_CLIENT_CA_FILE or ADMIN_TOKEN to enable it")
 	}
+	if adminAuth != nil {
+		admin := router.Group("/admin", adminAuth)
+		admin.GET("/stats", handler.AdminStats)
+		admin.GET("/reconcile", handler.AdminReconcileReport)
+		admin.POST("/reconcile", handler.AdminReconcile)
+	}
+
+	// Shared links land on a confirmation page, so link previews never
+	// consume a download
+	router.GET("/d/:token", middleware.TokenRoute, handler.DownloadPage)
+	router.POST("/d/:token", middleware.TokenRoute, handler.ConfirmDownload)
 
 	// Web interface routes
-	router.GET("/", func(c *gin.Context) {
-		c.HTML(200, "index.html", gin.H{
-			"title": "ShreadBox - Secure File Sharing",
+	router.GET("/", page("index.html", "ShreadBox - Secure File Sharing", "upload"))
+	router.GET("/shares", page("shares.html", "ShreadBox - My Shares", "shares"))
+	router.GET("/status", page("status.html", "ShreadBox - Check a Link", "status"))
+}
+
+// page renders a web interface page that needs no data from the server
+func page(name, title, nav string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.HTML(200, name, gin.H{
+			"title": title,
+			"nav":   nav,
 		})
-	})
+	}
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/config"
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/hardiksharma/shreadbox/internal/contenttype"
+	"github.com/hardiksharma/shreadbox/internal/docs"
+	"github.com/hardiksharma/shreadbox/internal/handlers"
+	"github.com/hardiksharma/shreadbox/internal/quota"
+	"github.com/hardiksharma/shreadbox/internal/server"
+	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter builds the router exactly as main does
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	dir := t.TempDir()
+
+	cfg := &config.Config{
+		MaxFileSize: 10 << 20,
+		StoragePath: filepath.Join(dir, "storage"),
+		APIKeysFile: filepath.Join(dir, "apikeys.json"),
+		AdminToken:  "test-admin-token",
+	}
+
+	storageService, err := storage.NewStorage(cfg.StoragePath)
+	require.NoError(t, err)
+	keyStore, err := auth.NewKeyStore(cfg.APIKeysFile)
+	require.NoError(t, err)
+
+	handler := handlers.NewHandler(storageService, quota.NewManager(quota.Limits{}), handlers.Options{
+		MaxFileSize: cfg.MaxFileSize,
+		TypePolicy:  contenttype.NewPolicy(nil, nil, nil, nil),
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	setupRoutes(router, handler, server.New(":0", router, time.Second), keyStore, cfg)
+	return router
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	var spec struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(docs.Spec(), &spec))
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+	// Gin's ":param" and "*param" become OpenAPI's "{param}"
+	param := regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+	documented := make(map[string]bool)
+	for _, route := range newTestRouter(t).Routes() {
+		path := param.ReplaceAllString(route.Path, "{$1}")
+		method := strings.ToLower(route.Method)
+		documented[method+" "+path] = true
+
+		operations, exists := spec.Paths[path]
+		if !assert.True(t, exists, "route %s %s is missing from openapi.json", route.Method, path) {
+			continue
+		}
+		assert.Contains(t, operations, method, "route %s %s is missing from openapi.json", route.Method, path)
+	}
+
+	// The spec must not describe routes that do not exist either
+	httpMethods := map[string]bool{"get": true, "put": true, "post": true, "delete": true, "patch": true, "head": true, "options": true}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if !httpMethods[method] {
+				continue
+			}
+			assert.True(t, documented[method+" "+path], "openapi.json documents unregistered route %s %s", strings.ToUpper(method), path)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+)
+
+const usage = `Usage: shreadbox-client <command> [flags]
+
+Commands:
+  keygen [-o FILE]
+  upload [-to PUBLIC_KEY]... [-expiry DURATION] [-downloads N] [-message TEXT] FILE
+  download [-i IDENTITY_FILE] [-o FILE] [-sha256 HEX] TOKEN|DOWNLOAD_URL
+
+Environment:
+  SHREADBOX_URL      server URL (default http://localhost:8080)
+  SHREADBOX_API_KEY  API key sent with uploads and file request downloads
+`
+
+// recipientList collects repeated -to flags
+type recipientList []string
+
+func (r *recipientList) String() string     { return strings.Join(*r, ",") }
+func (r *recipientList) Set(v string) error { *r = append(*r, v); return nil }
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	server := strings.TrimRight(os.Getenv("SHREADBOX_URL"), "/")
+	if server == "" {
+		server = "http://localhost:8080"
+	}
+
+	switch os.Args[1] {
+	case "keygen":
+		keygen(os.Args[2:])
+	case "upload":
+		upload(server, os.Args[2:])
+	case "download":
+		download(server, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// keygen creates an age X25519 identity. The public key is what uploaders
+// seal shares to; the identity file must stay private.
+func keygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	output := flags.String("o", "", "write the identity to FILE instead of stdout")
+	flags.Parse(args)
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+
+	contents := fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient(), identity)
+	if *output == "" {
+		fmt.Print(contents)
+		return
+	}
+	if err := os.WriteFile(*output, []byte(contents), 0600); err != nil {
+		log.Fatalf("Failed to write identity: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Public key: %s\n", identity.Recipient())
+}
+
+func upload(server string, args []string) {
+	var recipients recipientList
+	flags := flag.NewFlagSet("upload", flag.ExitOnError)
+	flags.Var(&recipients, "to", "seal the share to an age public key (repeatable)")
+	expiry := flags.String("expiry", "24h", "time until the share expires")
+	downloads := flags.Int("downloads", 1, "number of downloads allowed")
+	message := flags.String("message", "", "note shown to the recipient")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Catch typos before uploading anything
+	if _, err := encryption.ParseRecipients(recipients.String()); err != nil {
+		log.Fatal(err)
+	}
+
+	path := flags.Arg(0)
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The server refuses the file if it does not arrive intact
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(part, hash), file); err != nil {
+		log.Fatalf("Failed to read file: %v", err)
+	}
+	form.WriteField("expected_digest", hex.EncodeToString(hash.Sum(nil)))
+	form.WriteField("expiry_time", *expiry)
+	form.WriteField("downloads_allowed", strconv.Itoa(*downloads))
+	if *message != "" {
+		form.WriteField("message", *message)
+	}
+	if len(recipients) > 0 {
+		form.WriteField("recipient_keys", recipients.String())
+	}
+	form.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server+"/api/upload", &body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if apiKey := os.Getenv("SHREADBOX_API_KEY"); apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatalf("Upload failed: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(resp)
+
+	var result struct {
+		Token       string            `json:"token"`
+		ExpiresAt   string            `json:"expires_at"`
+		DownloadURL string            `json:"download_url"`
+		Digests     map[string]string `json:"digests"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Fatalf("Invalid response: %v", err)
+	}
+
+	fmt.Printf("Token:   %s\n", result.Token)
+	fmt.Printf("URL:     %s%s\n", server, result.DownloadURL)
+	fmt.Printf("Expires: %s\n", result.ExpiresAt)
+	fmt.Printf("SHA-256: %s\n", result.Digests["sha-256"])
+}
+
+func download(server string, args []string) {
+	flags := flag.NewFlagSet("download", flag.ExitOnError)
+	identityFile := flags.String("i", "", "age identity file for sealed shares")
+	output := flags.String("o", "", "output file (default: the shared file name)")
+	expected := flags.String("sha256", "", "refuse the file unless its SHA-256 matches")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Files received by a file request are fetched by the download_url
+	// listed for them, with the key that created the request
+	requested := strings.HasPrefix(flags.Arg(0), "/api/me/requests/")
+	target := server + "/api/download/" + url.PathEscape(flags.Arg(0))
+	if requested {
+		target = server + flags.Arg(0)
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("X-ShreadBox-Client", "shreadbox-client")
+	if apiKey := os.Getenv("SHREADBOX_API_KEY"); apiKey != "" && requested {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatalf("Download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	checkResponse(resp)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("Download failed: %v", err)
+	}
+
+	name := "download"
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = filepath.Base(params["filename"])
+	}
+
+	// Sealed shares are opened locally, the server never had the key
+	if resp.Header.Get("Content-Type") == encryption.EnvelopeContentType {
+		if *identityFile == "" {
+			log.Fatal("This share is sealed to a public key, pass your identity with -i")
+		}
+		identities := loadIdentities(*identityFile)
+		if data, err = encryption.OpenEnvelope(data, identities...); err != nil {
+			log.Fatalf("Failed to decrypt: %v", err)
+		}
+		name = strings.TrimSuffix(name, ".sbx")
+	}
+
+	// Check the file against the uploader's digest and any given locally
+	sum := sha256.Sum256(data)
+	if served := reprDigest(resp.Header.Get("Repr-Digest"), "sha-256"); served != nil && !bytes.Equal(served, sum[:]) {
+		log.Fatal("Downloaded file does not match the digest sent by the server")
+	}
+	if *expected != "" && !strings.EqualFold(*expected, hex.EncodeToString(sum[:])) {
+		log.Fatalf("Downloaded file does not match the expected SHA-256, got %x", sum)
+	}
+
+	if *output == "" {
+		*output = name
+	}
+	if err := os.WriteFile(*output, data, 0600); err != nil {
+		log.Fatalf("Failed to write file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Saved %s (%d bytes)\n", *output, len(data))
+}
+
+// reprDigest returns the digest for algorithm from a Repr-Digest field
+func reprDigest(field, algorithm string) []byte {
+	for _, member := range strings.Split(field, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || name != algorithm {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err == nil {
+			return sum
+		}
+	}
+	return nil
+}
+
+func loadIdentities(path string) []age.Identity {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open identity file: %v", err)
+	}
+	defer file.Close()
+
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		log.Fatalf("Failed to parse identity file: %v", err)
+	}
+	return identities
+}
+
+// checkResponse exits with the server's problem detail on failure
+func checkResponse(resp *http.Response) {
+	if resp.StatusCode < 300 {
+		return
+	}
+
+	var problem struct {
+		Detail string `json:"detail"`
+		Title  string `json:"title"`
+	}
+	json.NewDecoder(resp.Body).Decode(&problem)
+	if problem.Detail == "" {
+		problem.Detail = problem.Title
+	}
+	log.Fatalf("Server returned %s: %s", resp.Status, problem.Detail)
+}
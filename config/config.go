@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,15 +13,152 @@ type Config struct {
 	MaxFileSize     int64 // in bytes
 	StoragePath     string
 	CleanupInterval time.Duration
+	ShutdownTimeout time.Duration
+	ShutdownDelay   time.Duration // reported not ready before connections are refused
+
+	// ReconcileInterval is how often the storage directory is checked
+	// against the shares in memory, zero checks only on start
+	ReconcileInterval time.Duration
+
+	// TLS settings, TLS is enabled when both cert and key files are set
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCAFile   string // enables mTLS for the admin API
+	TLSReloadInterval time.Duration
+	HTTPRedirectPort  string // plain HTTP listener redirecting to HTTPS
+	HSTSMaxAge        time.Duration
+
+	// Upload quotas, zero means unlimited
+	ClientQuota     int64 // stored bytes per client
+	ClientMaxShares int   // active shares per client
+	StorageCapacity int64 // stored bytes across all clients
+	TrustedProxies  []string
+
+	// Authentication
+	APIKeysFile  string
+	AuthRequired bool   // reject anonymous uploads
+	AdminToken   string // enables the admin API without mTLS
+
+	// OpaqueErrors reports expired and exhausted shares as unknown (404)
+	// rather than gone (410), and on token routes also refused access and
+	// pending scans, hiding whether a token ever existed
+	OpaqueErrors bool
+
+	// Upload type policy, deny rules win and empty allow lists allow all
+	AllowedTypes      []string
+	DeniedTypes       []string
+	AllowedExtensions []string
+	DeniedExtensions  []string
+
+	// Malware scanning
+	Scanner      string // "clamd", "command" or empty to disable
+	ClamdAddress string // unix:/path or tcp:host:port
+	ScanCommand  string
+	ScanTimeout  time.Duration
+
+	// Webhooks, per-share notify URLs work even without global URLs
+	WebhookURLs           []string // receive events for every share
+	WebhookSecret         string   // signs deliveries to WebhookURLs, required with them
+	WebhookMaxAttempts    int
+	WebhookDeadLetterFile string
+	WebhookAllowPrivate   bool // allow loopback and private network targets
+
+	// Email notifications, enabled when SMTPHost is set
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	BaseURL      string // public URL used to build links in emails and QR codes
+
+	// LinkSecret signs download confirmation pages, set it when running
+	// several instances so pages work across them
+	LinkSecret string
+
+	// DedupSecret enables deduplicated uploads, identical files then share
+	// one blob. Changing it stops new uploads matching older blobs.
+	DedupSecret string
+
+	// Compression is applied to uploads before encryption: none, gzip or zstd
+	Compression string
+
+	// DigestBLAKE3 adds a BLAKE3 digest to the SHA-256 of every upload
+	DigestBLAKE3 bool
+
+	// TokenFormat is how share tokens are generated: uuid, base58 or words,
+	// with TokenWords words in word codes
+	TokenFormat string
+	TokenWords  int
+
+	// TokenMaxFailures unknown tokens may be looked up per client within
+	// TokenFailureWindow, 0 disables the limit
+	TokenMaxFailures   int
+	TokenFailureWindow time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	config := &Config{
 		Port:            getEnvOrDefault("PORT", "8080"),
-		MaxFileSize:     getMaxFileSizeBytes(),
+		MaxFileSize:     getMegabytesOrDefault("MAX_FILE_SIZE", 10),
 		StoragePath:     getEnvOrDefault("STORAGE_PATH", "./storage"),
-		CleanupInterval: getCleanupInterval(),
+		CleanupInterval: getDurationOrDefault("CLEANUP_INTERVAL", 5*time.Minute),
+		ShutdownTimeout: getDurationOrDefault("SHUTDOWN_TIMEOUT", 30*time.Second),
+		ShutdownDelay:   getDurationOrDefault("SHUTDOWN_DELAY", 0),
+
+		ReconcileInterval: getDurationOrDefault("RECONCILE_INTERVAL", time.Hour),
+
+		TLSCertFile:       os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:        os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile:   os.Getenv("TLS_CLIENT_CA_FILE"),
+		TLSReloadInterval: getDurationOrDefault("TLS_RELOAD_INTERVAL", time.Minute),
+		HTTPRedirectPort:  os.Getenv("HTTP_REDIRECT_PORT"),
+		HSTSMaxAge:        getDurationOrDefault("HSTS_MAX_AGE", 365*24*time.Hour),
+
+		ClientQuota:     getMegabytesOrDefault("CLIENT_QUOTA", 0),
+		ClientMaxShares: getIntOrDefault("CLIENT_MAX_SHARES", 0),
+		StorageCapacity: getMegabytesOrDefault("STORAGE_CAPACITY", 0),
+		TrustedProxies:  getListOrDefault("TRUSTED_PROXIES", nil),
+
+		APIKeysFile:  getEnvOrDefault("API_KEYS_FILE", "./apikeys.json"),
+		AuthRequired: getBoolOrDefault("AUTH_REQUIRED", false),
+		AdminToken:   os.Getenv("ADMIN_TOKEN"),
+
+		OpaqueErrors: getBoolOrDefault("OPAQUE_ERRORS", false),
+
+		AllowedTypes:      getListOrDefault("ALLOWED_TYPES", nil),
+		DeniedTypes:       getListOrDefault("DENIED_TYPES", nil),
+		AllowedExtensions: getListOrDefault("ALLOWED_EXTENSIONS", nil),
+		DeniedExtensions:  getListOrDefault("DENIED_EXTENSIONS", nil),
+
+		Scanner:      os.Getenv("SCANNER"),
+		ClamdAddress: getEnvOrDefault("CLAMD_ADDRESS", "unix:/var/run/clamav/clamd.ctl"),
+		ScanCommand:  getEnvOrDefault("SCAN_COMMAND", "clamscan --no-summary -"),
+		ScanTimeout:  getDurationOrDefault("SCAN_TIMEOUT", 2*time.Minute),
+
+		WebhookURLs:           getListOrDefault("WEBHOOK_URLS", nil),
+		WebhookSecret:         os.Getenv("WEBHOOK_SECRET"),
+		WebhookMaxAttempts:    getIntOrDefault("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookDeadLetterFile: getEnvOrDefault("WEBHOOK_DEAD_LETTER_FILE", "./webhooks-failed.jsonl"),
+		WebhookAllowPrivate:   getBoolOrDefault("WEBHOOK_ALLOW_PRIVATE", false),
+
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvOrDefault("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     getEnvOrDefault("SMTP_FROM", "ShreadBox <noreply@localhost>"),
+		BaseURL:      getEnvOrDefault("BASE_URL", "http://localhost:8080"),
+
+		LinkSecret:  os.Getenv("LINK_SECRET"),
+		DedupSecret: os.Getenv("DEDUP_SECRET"),
+		Compression: getEnvOrDefault("COMPRESSION", "none"),
+
+		DigestBLAKE3: getBoolOrDefault("DIGEST_BLAKE3", false),
+
+		TokenFormat:        getEnvOrDefault("TOKEN_FORMAT", "uuid"),
+		TokenWords:         getIntOrDefault("TOKEN_WORDS", 3),
+		TokenMaxFailures:   getIntOrDefault("TOKEN_MAX_FAILURES", 10),
+		TokenFailureWindow: getDurationOrDefault("TOKEN_FAILURE_WINDOW", 15*time.Minute),
 	}
 
 	// Ensure storage directory exists
@@ -31,6 +169,11 @@ func LoadConfig() *Config {
 	return config
 }
 
+// TLSEnabled reports whether the server should serve HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -38,20 +181,53 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getMaxFileSizeBytes() int64 {
-	maxSizeMB := getEnvOrDefault("MAX_FILE_SIZE", "10") // Default 10MB
-	maxSize, err := strconv.ParseInt(maxSizeMB, 10, 64)
+// getMegabytesOrDefault parses a size in MB and returns it in bytes
+func getMegabytesOrDefault(key string, defaultMB int64) int64 {
+	sizeMB, err := strconv.ParseInt(getEnvOrDefault(key, ""), 10, 64)
 	if err != nil {
-		maxSize = 10 // Default to 10MB if parsing fails
+		sizeMB = defaultMB
+	}
+	return sizeMB * 1024 * 1024
+}
+
+func getIntOrDefault(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnvOrDefault(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getBoolOrDefault(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnvOrDefault(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getListOrDefault parses a comma separated list
+func getListOrDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
-	return maxSize * 1024 * 1024 // Convert to bytes
+	return list
 }
 
-func getCleanupInterval() time.Duration {
-	interval := getEnvOrDefault("CLEANUP_INTERVAL", "5m")
-	duration, err := time.ParseDuration(interval)
+// getDurationOrDefault parses a duration such as "1h", "5m" or "30s",
+// falling back to the default if the variable is unset or invalid
+func getDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(getEnvOrDefault(key, ""))
 	if err != nil {
-		duration = 5 * time.Minute // Default to 5 minutes if parsing fails
+		return defaultValue
 	}
 	return duration
 }
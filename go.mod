@@ -3,10 +3,16 @@ module github.com/hardiksharma/shreadbox
 go 1.24.5
 
 require (
+	filippo.io/age v1.2.1
 	github.com/gin-gonic/gin v1.9.1
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
+	github.com/klauspost/compress v1.18.0
+	github.com/ledongthuc/pdf v0.0.0-20260907135840-6c8c28e0e8a0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/image v0.29.0
+	lukechampine.com/blake3 v1.4.1
 )
 
 require (
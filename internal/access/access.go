@@ -0,0 +1,224 @@
+package access
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// maxDenials caps the denied attempts remembered per share
+const maxDenials = 50
+
+var (
+	ErrIPNotAllowed   = domain.NewError(domain.ErrForbidden, "your network is not allowed to download this file")
+	ErrNotYetActive   = domain.NewError(domain.ErrForbidden, "this file is not available yet")
+	ErrOutsideWindow  = domain.NewError(domain.ErrForbidden, "this file cannot be downloaded at this time")
+	ErrTooManyClients = domain.NewError(domain.ErrForbidden, "this file has been downloaded from too many addresses")
+)
+
+// Rules are the access rules requested at upload, as submitted
+type Rules struct {
+	AllowedIPs string // comma separated CIDRs or addresses
+	NotBefore  string // RFC 3339
+	Windows    string // e.g. "mon-fri 09-17; sat 10-14"
+	Timezone   string // IANA name windows are evaluated in, default UTC
+	MaxClients string // distinct client addresses allowed to download
+}
+
+// Policy restricts who may download a share and when
+type Policy struct {
+	AllowedNets []netip.Prefix
+	NotBefore   time.Time
+	Windows     []Window
+	Location    *time.Location
+	MaxClients  int
+}
+
+// Window allows downloads on the given weekdays between StartHour
+// (inclusive) and EndHour (exclusive)
+type Window struct {
+	Days      [7]bool // indexed by time.Weekday
+	StartHour int
+	EndHour   int
+}
+
+// Denial records a refused download attempt
+type Denial struct {
+	At     time.Time `json:"at"`
+	IP     string    `json:"ip"`
+	Reason string    `json:"reason"`
+}
+
+// Parse validates rules and builds a policy, nil if no rules were given
+func Parse(rules Rules) (*Policy, error) {
+	if rules == (Rules{}) {
+		return nil, nil
+	}
+
+	policy := &Policy{Location: time.UTC}
+
+	for _, item := range strings.Split(rules.AllowedIPs, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		prefix, err := parsePrefix(item)
+		if err != nil {
+			return nil, invalid("allowed_ips", item)
+		}
+		policy.AllowedNets = append(policy.AllowedNets, prefix)
+	}
+
+	if rules.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, rules.NotBefore)
+		if err != nil {
+			return nil, invalid("not_before", rules.NotBefore)
+		}
+		policy.NotBefore = notBefore
+	}
+
+	if rules.Timezone != "" {
+		location, err := time.LoadLocation(rules.Timezone)
+		if err != nil {
+			return nil, invalid("access_timezone", rules.Timezone)
+		}
+		policy.Location = location
+	}
+
+	for _, item := range strings.Split(rules.Windows, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		window, err := parseWindow(item)
+		if err != nil {
+			return nil, invalid("access_windows", item)
+		}
+		policy.Windows = append(policy.Windows, window)
+	}
+
+	if rules.MaxClients != "" {
+		maxClients, err := strconv.Atoi(rules.MaxClients)
+		if err != nil || maxClients < 1 {
+			return nil, invalid("max_clients", rules.MaxClients)
+		}
+		policy.MaxClients = maxClients
+	}
+
+	return policy, nil
+}
+
+// Check decides whether ip may download at now. seen holds the addresses
+// that were already allowed; a new address counts towards MaxClients.
+func (p *Policy) Check(ip netip.Addr, now time.Time, seen []string) error {
+	ip = ip.Unmap()
+
+	if len(p.AllowedNets) > 0 && !slices.ContainsFunc(p.AllowedNets, func(prefix netip.Prefix) bool {
+		return prefix.Contains(ip)
+	}) {
+		return ErrIPNotAllowed
+	}
+
+	if now.Before(p.NotBefore) {
+		return ErrNotYetActive
+	}
+
+	if len(p.Windows) > 0 {
+		local := now.In(p.Location)
+		if !slices.ContainsFunc(p.Windows, func(window Window) bool {
+			return window.Days[local.Weekday()] && local.Hour() >= window.StartHour && local.Hour() < window.EndHour
+		}) {
+			return ErrOutsideWindow
+		}
+	}
+
+	if p.MaxClients > 0 && !slices.Contains(seen, ip.String()) && len(seen) >= p.MaxClients {
+		return ErrTooManyClients
+	}
+
+	return nil
+}
+
+// RecordDenial appends a denial, keeping only the most recent ones
+func RecordDenial(denials []Denial, denial Denial) []Denial {
+	denials = append(denials, denial)
+	if len(denials) > maxDenials {
+		denials = denials[len(denials)-maxDenials:]
+	}
+	return denials
+}
+
+// parsePrefix accepts CIDRs and bare addresses
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseWindow parses "DAYS HH-HH", where DAYS is "*", a day, a range such
+// as "mon-fri" or a comma separated list of those
+func parseWindow(value string) (Window, error) {
+	var window Window
+
+	days, hours, ok := strings.Cut(value, " ")
+	if !ok {
+		return window, fmt.Errorf("missing hours")
+	}
+
+	for _, part := range strings.Split(strings.ToLower(days), ",") {
+		if part == "*" {
+			window.Days = [7]bool{true, true, true, true, true, true, true}
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		start, ok1 := weekdays[first]
+		end, ok2 := weekdays[last]
+		if !ok1 || !ok2 {
+			return window, fmt.Errorf("unknown day %q", part)
+		}
+		for day := start; ; day = (day + 1) % 7 {
+			window.Days[day] = true
+			if day == end {
+				break
+			}
+		}
+	}
+
+	startHour, endHour, ok := strings.Cut(strings.TrimSpace(hours), "-")
+	if !ok {
+		return window, fmt.Errorf("hours must look like 09-17")
+	}
+	var err error
+	if window.StartHour, err = strconv.Atoi(startHour); err != nil {
+		return window, err
+	}
+	if window.EndHour, err = strconv.Atoi(endHour); err != nil {
+		return window, err
+	}
+	if window.StartHour < 0 || window.EndHour > 24 || window.StartHour >= window.EndHour {
+		return window, fmt.Errorf("hours out of range")
+	}
+	return window, nil
+}
+
+func invalid(field, value string) error {
+	return domain.NewError(domain.ErrInvalidInput, fmt.Sprintf("invalid %s: %q", field, value))
+}
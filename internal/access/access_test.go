@@ -0,0 +1,94 @@
+package access
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	policy, err := Parse(Rules{})
+	require.NoError(t, err)
+	assert.Nil(t, policy, "no rules means no policy")
+
+	policy, err = Parse(Rules{
+		AllowedIPs: "10.0.0.0/8, 192.0.2.7, 2001:db8::/32",
+		NotBefore:  "2030-01-02T09:00:00Z",
+		Windows:    "mon-fri 09-17; sat,sun 10-12",
+		Timezone:   "Europe/Berlin",
+		MaxClients: "2",
+	})
+	require.NoError(t, err)
+	assert.Len(t, policy.AllowedNets, 3)
+	assert.Equal(t, "192.0.2.7/32", policy.AllowedNets[1].String())
+	assert.Equal(t, time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC), policy.NotBefore)
+	assert.Equal(t, "Europe/Berlin", policy.Location.String())
+	assert.Equal(t, 2, policy.MaxClients)
+	require.Len(t, policy.Windows, 2)
+	assert.Equal(t, [7]bool{false, true, true, true, true, true, false}, policy.Windows[0].Days)
+	assert.Equal(t, [7]bool{true, false, false, false, false, false, true}, policy.Windows[1].Days)
+
+	// Day ranges may wrap around the week
+	policy, err = Parse(Rules{Windows: "fri-mon 00-24"})
+	require.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, false, false, false, true, true}, policy.Windows[0].Days)
+
+	invalid := []Rules{
+		{AllowedIPs: "10.0.0.0/33"},
+		{AllowedIPs: "example.com"},
+		{NotBefore: "tomorrow"},
+		{Windows: "mon-fri"},
+		{Windows: "someday 09-17"},
+		{Windows: "mon 17-09"},
+		{Windows: "mon 09-25"},
+		{Timezone: "Mars/Olympus"},
+		{MaxClients: "0"},
+	}
+	for _, rules := range invalid {
+		_, err := Parse(rules)
+		assert.Error(t, err, "%+v", rules)
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	// Wednesday 2030-01-02 12:00 UTC
+	noon := time.Date(2030, 1, 2, 12, 0, 0, 0, time.UTC)
+	inside := netip.MustParseAddr("10.1.2.3")
+	outside := netip.MustParseAddr("192.0.2.1")
+
+	policy, err := Parse(Rules{AllowedIPs: "10.0.0.0/8"})
+	require.NoError(t, err)
+	assert.NoError(t, policy.Check(inside, noon, nil))
+	assert.NoError(t, policy.Check(netip.MustParseAddr("::ffff:10.1.2.3"), noon, nil), "IPv4-mapped addresses match")
+	assert.ErrorIs(t, policy.Check(outside, noon, nil), ErrIPNotAllowed)
+
+	policy, err = Parse(Rules{NotBefore: "2030-01-02T13:00:00Z"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, policy.Check(inside, noon, nil), ErrNotYetActive)
+	assert.NoError(t, policy.Check(inside, noon.Add(time.Hour), nil))
+
+	// 12:00 UTC is 13:00 in Berlin
+	policy, err = Parse(Rules{Windows: "wed 13-14", Timezone: "Europe/Berlin"})
+	require.NoError(t, err)
+	assert.NoError(t, policy.Check(inside, noon, nil))
+	assert.ErrorIs(t, policy.Check(inside, noon.Add(time.Hour), nil), ErrOutsideWindow)
+	assert.ErrorIs(t, policy.Check(inside, noon.Add(24*time.Hour), nil), ErrOutsideWindow)
+
+	policy, err = Parse(Rules{MaxClients: "1"})
+	require.NoError(t, err)
+	assert.NoError(t, policy.Check(inside, noon, nil))
+	assert.NoError(t, policy.Check(inside, noon, []string{"10.1.2.3"}), "known clients may return")
+	assert.ErrorIs(t, policy.Check(outside, noon, []string{"10.1.2.3"}), ErrTooManyClients)
+}
+
+func TestRecordDenial(t *testing.T) {
+	var denials []Denial
+	for i := 0; i < maxDenials+5; i++ {
+		denials = RecordDenial(denials, Denial{IP: "192.0.2.1", Reason: string(rune('a' + i%26))})
+	}
+	assert.Len(t, denials, maxDenials)
+	assert.Equal(t, string(rune('a'+(maxDenials+4)%26)), denials[len(denials)-1].Reason)
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// keyPrefix makes API keys recognisable in logs and secret scanners
+const keyPrefix = "sbk_"
+
+var (
+	ErrInvalidKey  = domain.NewError(domain.ErrUnauthorized, "invalid API key")
+	ErrKeyNotFound = domain.NewError(domain.ErrNotFound, "API key not found")
+)
+
+// Policy limits what uploads made with a key may request, zero values
+// fall back to the server defaults
+type Policy struct {
+	MaxFileSize  int64         `json:"max_file_size,omitempty"` // in bytes
+	MaxExpiry    time.Duration `json:"max_expiry,omitempty"`
+	MaxDownloads int           `json:"max_downloads,omitempty"`
+}
+
+// Key represents an issued API key. Only the SHA-256 hash of the secret is
+// stored; the secret itself is shown once when the key is created.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Policy    Policy    `json:"policy"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyStore persists API keys in a JSON file. The file is re-read whenever it
+// changes on disk, so keys issued or revoked by the admin command take
+// effect without restarting the server.
+type KeyStore struct {
+	path    string
+	keys    map[string]*Key // by hash
+	modTime time.Time
+	mu      sync.Mutex
+}
+
+// NewKeyStore opens the key file at path, which need not exist yet
+func NewKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{
+		path: path,
+		keys: make(map[string]*Key),
+	}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create issues a new key and returns its secret
+func (s *KeyStore) Create(name string, policy Policy) (string, *Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return "", nil, err
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(secretBytes)
+	key := &Key{
+		ID:        hex.EncodeToString(idBytes),
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Policy:    policy,
+		CreatedAt: time.Now(),
+	}
+
+	s.keys[key.Hash] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.Hash)
+		return "", nil, err
+	}
+	return secret, key, nil
+}
+
+// Authenticate returns the key matching secret
+func (s *KeyStore) Authenticate(secret string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return nil, ErrInvalidKey
+	}
+
+	// Lookups are by hash, so timing reveals nothing about stored secrets
+	key, exists := s.keys[hashSecret(secret)]
+	if !exists {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// Revoke deletes the key with the given ID
+func (s *KeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	for hash, key := range s.keys {
+		if key.ID == id {
+			delete(s.keys, hash)
+			return s.save()
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// List returns all keys ordered by creation time
+func (s *KeyStore) List() ([]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// refresh reloads the key file if it changed since it was last read
+func (s *KeyStore) refresh() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.keys = make(map[string]*Key)
+		s.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat key file: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var list []*Key
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	keys := make(map[string]*Key, len(list))
+	for _, key := range list {
+		keys[key.Hash] = key
+	}
+	s.keys = keys
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// save atomically writes the keys to disk
+func (s *KeyStore) save() error {
+	list := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		list = append(list, key)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keys: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyStore_CreateAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	store, err := NewKeyStore(path)
+	require.NoError(t, err)
+
+	policy := Policy{MaxFileSize: 100 << 20, MaxExpiry: time.Hour, MaxDownloads: 5}
+	secret, key, err := store.Create("ci", policy)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, keyPrefix))
+	assert.Equal(t, policy, key.Policy)
+
+	// Only the hash is persisted
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), secret)
+	assert.Contains(t, string(data), key.Hash)
+
+	authenticated, err := store.Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authenticated.ID)
+
+	_, err = store.Authenticate(secret + "x")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = store.Authenticate("not-a-key")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestKeyStore_SharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+
+	// The server and the admin command open the same file
+	server, err := NewKeyStore(path)
+	require.NoError(t, err)
+	admin, err := NewKeyStore(path)
+	require.NoError(t, err)
+
+	secret, key, err := admin.Create("laptop", Policy{})
+	require.NoError(t, err)
+
+	_, err = server.Authenticate(secret)
+	require.NoError(t, err)
+
+	// Make sure the revocation gets a distinct modification time
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, admin.Revoke(key.ID))
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	_, err = server.Authenticate(secret)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	assert.ErrorIs(t, admin.Revoke(key.ID), ErrKeyNotFound)
+}
+
+func TestKeyStore_List(t *testing.T) {
+	store, err := NewKeyStore(filepath.Join(t.TempDir(), "apikeys.json"))
+	require.NoError(t, err)
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, first, err := store.Create("first", Policy{})
+	require.NoError(t, err)
+	_, second, err := store.Create("second", Policy{})
+	require.NoError(t, err)
+
+	keys, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, first.ID, keys[0].ID)
+	assert.Equal(t, second.ID, keys[1].ID)
+}
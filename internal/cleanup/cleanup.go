@@ -1,72 +1,165 @@
 package cleanup
 
 import (
+	"context"
 	"log"
+	"sync"
 	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/storage"
 )
 
-// Service represents the cleanup service
+// Service destroys shares when they expire. Each share is removed at its
+// exact expiry time; a periodic sweep catches anything missed, such as
+// shares whose downloads ran out. The storage directory is reconciled with
+// the shares in memory on start and every reconcile interval.
 type Service struct {
-	storage   StorageInterface
-	interval  time.Duration
-	stopChan  chan struct{}
-	isRunning bool
+	storage           StorageInterface
+	interval          time.Duration
+	reconcileInterval time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // StorageInterface defines the methods required from the storage service
 type StorageInterface interface {
-	CleanupExpired() error
+	CleanupExpired() (storage.CleanupStats, error)
+	ExpireDue(now time.Time) (storage.CleanupStats, error)
+	NextExpiry() (time.Time, bool)
+	ExpiryChanged() <-chan struct{}
+	Reconcile() (storage.ReconcileReport, error)
 }
 
-// NewService creates a new cleanup service
-func NewService(storage StorageInterface, interval time.Duration) *Service {
+// NewService creates a new cleanup service sweeping every interval and
+// reconciling every reconcileInterval, or only on start if it is zero
+func NewService(storage StorageInterface, interval, reconcileInterval time.Duration) *Service {
 	return &Service{
-		storage:  storage,
-		interval: interval,
-		stopChan: make(chan struct{}),
+		storage:           storage,
+		interval:          interval,
+		reconcileInterval: reconcileInterval,
 	}
 }
 
-// Start begins the cleanup routine
-func (s *Service) Start() {
-	if s.isRunning {
+// Start runs the service until ctx is cancelled or Stop is called. Starting
+// a running service does nothing.
+func (s *Service) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
 		return
 	}
 
-	s.isRunning = true
-	go s.cleanupRoutine()
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.run(ctx, s.done)
 }
 
-// Stop stops the cleanup routine
+// Stop stops the service and waits for a run in progress to finish. It is
+// safe to call more than once and from several goroutines.
 func (s *Service) Stop() {
-	if !s.isRunning {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel == nil {
 		return
 	}
+	cancel()
+	<-done
+}
+
+// Running reports whether the service has been started and not stopped
+func (s *Service) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancel != nil
+}
 
-	s.stopChan <- struct{}{}
-	s.isRunning = false
+// Sweep runs the safety net sweep immediately and returns its statistics
+func (s *Service) Sweep() (storage.CleanupStats, error) {
+	stats, err := s.storage.CleanupExpired()
+	logRun("Sweep", stats, err)
+	return stats, err
 }
 
-// cleanupRoutine runs the cleanup process at regular intervals
-func (s *Service) cleanupRoutine() {
-	ticker := time.NewTicker(s.interval)
-	defer ticker.Stop()
+// Reconcile checks the storage directory immediately and returns the report
+func (s *Service) Reconcile() (storage.ReconcileReport, error) {
+	report, err := s.storage.Reconcile()
+	logReconcile(report, err)
+	return report, err
+}
+
+func (s *Service) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	// Clear up after an unclean shutdown before anything else
+	s.Reconcile()
+
+	sweep := time.NewTicker(s.interval)
+	defer sweep.Stop()
+
+	var reconcile <-chan time.Time
+	if s.reconcileInterval > 0 {
+		ticker := time.NewTicker(s.reconcileInterval)
+		defer ticker.Stop()
+		reconcile = ticker.C
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 
 	for {
-		select {
-		case <-ticker.C:
-			if err := s.runCleanup(); err != nil {
-				log.Printf("Cleanup error: %v", err)
+		// Sleep until the next share expires
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
 			}
-		case <-s.stopChan:
+		}
+		if next, ok := s.storage.NextExpiry(); ok {
+			timer.Reset(time.Until(next))
+		}
+
+		select {
+		case <-ctx.Done():
 			log.Println("Cleanup service stopped")
 			return
+		case <-s.storage.ExpiryChanged():
+			// An earlier deadline was added, reschedule
+		case now := <-timer.C:
+			stats, err := s.storage.ExpireDue(now)
+			logRun("Expiry", stats, err)
+		case <-sweep.C:
+			s.Sweep()
+		case <-reconcile:
+			s.Reconcile()
 		}
 	}
 }
 
-// runCleanup executes a single cleanup operation
-func (s *Service) runCleanup() error {
-	log.Println("Running cleanup operation...")
-	return s.storage.CleanupExpired()
+// logRun reports runs that did something
+func logRun(kind string, stats storage.CleanupStats, err error) {
+	if err != nil {
+		log.Printf("%s cleanup error: %v", kind, err)
+	}
+	if stats.Removed() > 0 || stats.Requests > 0 {
+		log.Printf("%s cleanup removed %d expired and %d exhausted shares (%d bytes) and %d file requests in %s",
+			kind, stats.Expired, stats.Exhausted, stats.BytesFreed, stats.Requests, stats.Duration)
+	}
+}
+
+// logReconcile reports reconciliations that found problems
+func logReconcile(report storage.ReconcileReport, err error) {
+	if err != nil {
+		log.Printf("Reconcile error: %v", err)
+	}
+	if report.Problems() > 0 {
+		log.Printf("Reconcile of %d blobs and %d shares shredded %d orphans and %d staging files, dropped %d shares without a blob and quarantined %d damaged blobs (%d bytes) in %s",
+			report.BlobsScanned, report.SharesChecked, report.Orphans, report.Staging, report.MissingBlobs,
+			report.SizeMismatches+report.Unreadable, report.BytesFreed, report.Duration)
+	}
 }
@@ -1,97 +1,201 @@
 package cleanup
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+	"github.com/hardiksharma/shreadbox/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-// MockStorage is a mock implementation of StorageInterface
+// MockStorage is a mock implementation of StorageInterface with no shares
+// scheduled to expire
 type MockStorage struct {
 	mock.Mock
-	cleanupCalled bool
+	changed    chan struct{}
+	reconciled chan struct{}
 }
 
-func (m *MockStorage) CleanupExpired() error {
-	m.cleanupCalled = true
+func newMockStorage() *MockStorage {
+	return &MockStorage{
+		changed:    make(chan struct{}),
+		reconciled: make(chan struct{}, 10),
+	}
+}
+
+func (m *MockStorage) CleanupExpired() (storage.CleanupStats, error) {
 	args := m.Called()
-	return args.Error(0)
+	return args.Get(0).(storage.CleanupStats), args.Error(1)
+}
+
+func (m *MockStorage) ExpireDue(now time.Time) (storage.CleanupStats, error) {
+	return storage.CleanupStats{}, nil
+}
+
+func (m *MockStorage) NextExpiry() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (m *MockStorage) ExpiryChanged() <-chan struct{} {
+	return m.changed
+}
+
+func (m *MockStorage) Reconcile() (storage.ReconcileReport, error) {
+	select {
+	case m.reconciled <- struct{}{}:
+	default:
+	}
+	return storage.ReconcileReport{}, nil
 }
 
 func TestNewService(t *testing.T) {
-	storage := new(MockStorage)
+	storage := newMockStorage()
 	interval := 5 * time.Minute
 
-	service := NewService(storage, interval)
+	service := NewService(storage, interval, 0)
 	assert.NotNil(t, service)
 	assert.Equal(t, interval, service.interval)
-	assert.False(t, service.isRunning)
+	assert.False(t, service.Running())
 }
 
 func TestService_StartStop(t *testing.T) {
-	storage := new(MockStorage)
-	interval := 100 * time.Millisecond
-	service := NewService(storage, interval)
+	mockStorage := newMockStorage()
+	interval := 50 * time.Millisecond
+	service := NewService(mockStorage, interval, 0)
 
 	// Setup mock expectations
-	storage.On("CleanupExpired").Return(nil)
+	swept := make(chan struct{}, 1)
+	mockStorage.On("CleanupExpired").Return(storage.CleanupStats{}, nil).Run(func(mock.Arguments) {
+		select {
+		case swept <- struct{}{}:
+		default:
+		}
+	})
 
 	// Test Start
-	service.Start()
-	assert.True(t, service.isRunning)
+	service.Start(context.Background())
+	assert.True(t, service.Running())
 
-	// Wait for at least one cleanup cycle
-	time.Sleep(interval * 2)
+	// Wait for at least one sweep
+	select {
+	case <-swept:
+	case <-time.After(time.Second):
+		t.Fatal("sweep did not run")
+	}
 
 	// Test Stop
 	service.Stop()
-	assert.False(t, service.isRunning)
-
-	// Verify cleanup was called
-	assert.True(t, storage.cleanupCalled)
-	storage.AssertExpectations(t)
+	assert.False(t, service.Running())
+	mockStorage.AssertExpectations(t)
 }
 
 func TestService_MultipleStartStop(t *testing.T) {
-	storage := new(MockStorage)
-	service := NewService(storage, time.Minute)
-
-	// Setup mock expectations
-	storage.On("CleanupExpired").Return(nil)
+	service := NewService(newMockStorage(), time.Minute, 0)
 
 	// Test multiple starts
-	service.Start()
-	assert.True(t, service.isRunning)
-	service.Start() // Should not affect isRunning state
-	assert.True(t, service.isRunning)
+	service.Start(context.Background())
+	service.Start(context.Background())
+	assert.True(t, service.Running())
+
+	// Concurrent and repeated stops neither block nor panic
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service.Stop()
+		}()
+	}
+	wg.Wait()
+	service.Stop()
+	assert.False(t, service.Running())
 
-	// Test multiple stops
+	// The service can be started again
+	service.Start(context.Background())
+	assert.True(t, service.Running())
 	service.Stop()
-	assert.False(t, service.isRunning)
-	service.Stop() // Should not affect isRunning state
-	assert.False(t, service.isRunning)
 }
 
-func TestService_CleanupError(t *testing.T) {
-	storage := new(MockStorage)
-	interval := 100 * time.Millisecond
-	service := NewService(storage, interval)
+func TestService_StopsWithContext(t *testing.T) {
+	service := NewService(newMockStorage(), time.Minute, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	service.Start(ctx)
+	cancel()
+
+	// Stop returns promptly once the context has ended the run
+	done := make(chan struct{})
+	go func() {
+		service.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked after the context was cancelled")
+	}
+}
 
-	// Setup mock to return an error
-	storage.On("CleanupExpired").Return(error(nil))
+func TestService_Sweep(t *testing.T) {
+	mockStorage := newMockStorage()
+	service := NewService(mockStorage, time.Minute, 0)
 
-	// Start service
-	service.Start()
+	expected := storage.CleanupStats{Scanned: 10, Expired: 2, Exhausted: 1, BytesFreed: 300}
+	mockStorage.On("CleanupExpired").Return(expected, errors.New("disk error")).Once()
 
-	// Wait for at least one cleanup cycle
-	time.Sleep(interval * 2)
+	// Statistics and errors are returned to the caller
+	stats, err := service.Sweep()
+	assert.Equal(t, expected, stats)
+	assert.EqualError(t, err, "disk error")
+	mockStorage.AssertExpectations(t)
+}
 
-	// Stop service
-	service.Stop()
+func TestService_Reconciles(t *testing.T) {
+	mockStorage := newMockStorage()
+	service := NewService(mockStorage, time.Hour, 20*time.Millisecond)
+	service.Start(context.Background())
+	defer service.Stop()
+
+	// Once on start, then on schedule
+	for i := 0; i < 2; i++ {
+		select {
+		case <-mockStorage.reconciled:
+		case <-time.After(time.Second):
+			t.Fatal("reconcile did not run")
+		}
+	}
+}
+
+func TestService_ExpiresOnTime(t *testing.T) {
+	store, err := storage.NewStorage(t.TempDir())
+	require.NoError(t, err)
+
+	// The sweep never runs during the test, shares must expire on schedule
+	service := NewService(store, time.Hour, 0)
+	service.Start(context.Background())
+	defer service.Stop()
+
+	// Blobs must look encrypted to survive the reconcile on start
+	blob, err := encryption.Encrypt([]byte("artifact"), make([]byte, encryption.KeySize))
+	require.NoError(t, err)
+	late := &storage.FileMetadata{ExpiresAt: time.Now().Add(time.Hour), DownloadsLeft: 1}
+	require.NoError(t, store.SaveFile(blob, late))
+
+	// Added after the service went to sleep, and due sooner
+	soon := &storage.FileMetadata{ExpiresAt: time.Now().Add(100 * time.Millisecond), DownloadsLeft: 1}
+	require.NoError(t, store.SaveFile(blob, soon))
+
+	assert.Eventually(t, func() bool {
+		_, err := store.GetFileMetadata(soon.ID)
+		return errors.Is(err, storage.ErrNotFound)
+	}, 2*time.Second, 10*time.Millisecond)
 
-	// Verify cleanup was still called despite error
-	assert.True(t, storage.cleanupCalled)
-	storage.AssertExpectations(t)
+	_, err = store.GetFileMetadata(late.ID)
+	assert.NoError(t, err)
 }
@@ -0,0 +1,39 @@
+package contenttype
+
+// compressedTypes are formats that are already compressed, compressing
+// them again only costs time
+var compressedTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"image/heic",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/pdf",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"application/vnd.rar",
+	"application/x-rar-compressed",
+	"application/java-archive",
+	"application/epub+zip",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"application/vnd.oasis.opendocument.text",
+	"application/vnd.oasis.opendocument.spreadsheet",
+	"application/vnd.oasis.opendocument.presentation",
+}
+
+// Compressed reports whether files of contentType are already compressed
+func Compressed(contentType string) bool {
+	return matchesAny(compressedTypes, mediaType(contentType))
+}
@@ -0,0 +1,125 @@
+package contenttype
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// sniffLen is the number of leading bytes examined by http.DetectContentType
+const sniffLen = 512
+
+// ErrTypeNotAllowed is returned for files rejected by the type policy
+var ErrTypeNotAllowed = domain.NewError(domain.ErrUnsupportedType, "file type is not allowed")
+
+// genericTypes are sniffing results too vague to override a file extension
+var genericTypes = map[string]bool{
+	"application/octet-stream": true,
+	"text/plain":               true,
+	"text/xml":                 true,
+	"application/zip":          true, // also office documents, jars, ...
+}
+
+// Detect determines a file's MIME type from its leading bytes. The client's
+// Content-Type is never trusted; the file extension is only consulted when
+// the bytes themselves are inconclusive.
+func Detect(fileName string, data []byte) string {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	sniffed := http.DetectContentType(data)
+
+	if genericTypes[mediaType(sniffed)] {
+		if byExtension := mime.TypeByExtension(Extension(fileName)); byExtension != "" {
+			return byExtension
+		}
+	}
+	return sniffed
+}
+
+// Extension returns the lower-cased extension of fileName, including the dot
+func Extension(fileName string) string {
+	return strings.ToLower(filepath.Ext(fileName))
+}
+
+// Policy decides which file types may be uploaded. Deny rules win over
+// allow rules, and empty allow lists allow everything.
+type Policy struct {
+	allowedTypes      []string
+	deniedTypes       []string
+	allowedExtensions map[string]bool
+	deniedExtensions  map[string]bool
+}
+
+// NewPolicy creates a policy. Types may use wildcards such as "image/*";
+// extensions may be given with or without the leading dot.
+func NewPolicy(allowedTypes, deniedTypes, allowedExtensions, deniedExtensions []string) *Policy {
+	return &Policy{
+		allowedTypes:      normalizeTypes(allowedTypes),
+		deniedTypes:       normalizeTypes(deniedTypes),
+		allowedExtensions: extensionSet(allowedExtensions),
+		deniedExtensions:  extensionSet(deniedExtensions),
+	}
+}
+
+// Check returns ErrTypeNotAllowed if the file may not be uploaded
+func (p *Policy) Check(fileName, contentType string) error {
+	typ := mediaType(contentType)
+	ext := Extension(fileName)
+
+	if matchesAny(p.deniedTypes, typ) || p.deniedExtensions[ext] {
+		return ErrTypeNotAllowed
+	}
+	if len(p.allowedTypes) > 0 && !matchesAny(p.allowedTypes, typ) {
+		return ErrTypeNotAllowed
+	}
+	if len(p.allowedExtensions) > 0 && !p.allowedExtensions[ext] {
+		return ErrTypeNotAllowed
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, typ string) bool {
+	for _, pattern := range patterns {
+		if prefix, found := strings.CutSuffix(pattern, "/*"); found {
+			if strings.HasPrefix(typ, prefix+"/") {
+				return true
+			}
+		} else if pattern == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaType strips parameters such as charset from a content type
+func mediaType(contentType string) string {
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return typ
+}
+
+func normalizeTypes(types []string) []string {
+	normalized := make([]string, 0, len(types))
+	for _, typ := range types {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(typ)))
+	}
+	return normalized
+}
+
+func extensionSet(extensions []string) map[string]bool {
+	set := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	return set
+}
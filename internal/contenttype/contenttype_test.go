@@ -0,0 +1,93 @@
+package contenttype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+		expected string
+	}{
+		{"png by magic bytes", "image.png", pngHeader, "image/png"},
+		{"png with misleading extension", "notes.txt", pngHeader, "image/png"},
+		{"html disguised as image", "cat.jpg", []byte("<!DOCTYPE html><script>alert(1)</script>"), "text/html; charset=utf-8"},
+		{"svg refined by extension", "logo.svg", []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), "image/svg+xml"},
+		{"csv refined by extension", "data.csv", []byte("a,b\n1,2\n"), "text/csv; charset=utf-8"},
+		{"unknown binary", "blob", []byte{0x00, 0x01, 0x02}, "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Detect(tt.fileName, tt.data))
+		})
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      *Policy
+		fileName    string
+		contentType string
+		allowed     bool
+	}{
+		{"empty policy", NewPolicy(nil, nil, nil, nil), "a.exe", "application/octet-stream", true},
+		{"denied type", NewPolicy(nil, []string{"text/html"}, nil, nil), "a.txt", "text/html; charset=utf-8", false},
+		{"denied wildcard", NewPolicy(nil, []string{"image/*"}, nil, nil), "a.svg", "image/svg+xml", false},
+		{"denied extension", NewPolicy(nil, nil, nil, []string{"exe"}), "setup.EXE", "application/octet-stream", false},
+		{"allowed type", NewPolicy([]string{"application/pdf", "image/*"}, nil, nil, nil), "a.png", "image/png", true},
+		{"not in allowed types", NewPolicy([]string{"application/pdf"}, nil, nil, nil), "a.png", "image/png", false},
+		{"deny beats allow", NewPolicy([]string{"image/*"}, []string{"image/svg+xml"}, nil, nil), "a.svg", "image/svg+xml", false},
+		{"allowed extension", NewPolicy(nil, nil, []string{".log", ".txt"}, nil), "app.log", "text/plain", true},
+		{"not in allowed extensions", NewPolicy(nil, nil, []string{".log"}, nil), "app.sh", "text/plain", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.fileName, tt.contentType)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrTypeNotAllowed)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		expected string
+	}{
+		{"plain", "report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`},
+		{"spaces", "my report.pdf", `attachment; filename="my report.pdf"; filename*=UTF-8''my%20report.pdf`},
+		{"unicode", "résumé.pdf", `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"header injection", "a\";\r\nSet-Cookie: x=1.txt", `attachment; filename="a_;__Set-Cookie: x=1.txt"; filename*=UTF-8''a%22%3B%0D%0ASet-Cookie%3A%20x%3D1.txt`},
+		{"path traversal", "../../etc/passwd", `attachment; filename="passwd"; filename*=UTF-8''passwd`},
+		{"windows path", `C:\Users\me\secret.txt`, `attachment; filename="secret.txt"; filename*=UTF-8''secret.txt`},
+		{"empty", "", `attachment; filename="download"; filename*=UTF-8''download`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ContentDisposition("attachment", tt.fileName))
+		})
+	}
+}
+
+func TestCompressed(t *testing.T) {
+	assert.True(t, Compressed("image/jpeg"))
+	assert.True(t, Compressed("video/mp4"))
+	assert.True(t, Compressed("application/zip"))
+	assert.False(t, Compressed("text/plain; charset=utf-8"))
+	assert.False(t, Compressed("text/csv"))
+	assert.False(t, Compressed("application/octet-stream"))
+}
@@ -0,0 +1,58 @@
+package contenttype
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ContentDisposition builds an RFC 6266 Content-Disposition header value.
+// The quoted filename parameter carries an ASCII fallback for old clients
+// and filename* carries the exact name, percent-encoded per RFC 5987.
+func ContentDisposition(disposition, fileName string) string {
+	fileName = filepath.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if fileName == "." || fileName == "/" {
+		fileName = "download"
+	}
+
+	return disposition + `; filename="` + asciiFallback(fileName) + `"; filename*=UTF-8''` + encodeRFC5987(fileName)
+}
+
+// asciiFallback replaces characters that cannot appear in a quoted ASCII
+// filename parameter
+func asciiFallback(fileName string) string {
+	var b strings.Builder
+	for _, r := range fileName {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// encodeRFC5987 percent-encodes everything outside RFC 5987's attr-char set
+func encodeRFC5987(value string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,113 @@
+// Package digest computes and checks integrity digests of shared files, so
+// recipients can verify they received exactly what was uploaded.
+package digest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+	"lukechampine.com/blake3"
+)
+
+// Algorithm names, as registered for the HTTP Repr-Digest field
+const (
+	SHA256 = "sha-256"
+	BLAKE3 = "blake3"
+)
+
+var (
+	ErrInvalidDigest = domain.NewError(domain.ErrInvalidInput, `expected digest must look like "sha-256=:BASE64:" or be a hex SHA-256`)
+	ErrMismatch      = domain.NewError(domain.ErrInvalidInput, "file does not match the expected digest")
+)
+
+// Digests of a file's plaintext. BLAKE3 is only computed when enabled.
+type Digests struct {
+	SHA256 []byte `json:"sha256"`
+	BLAKE3 []byte `json:"blake3,omitempty"`
+}
+
+// Compute digests data with SHA-256 and, if withBLAKE3 is set, BLAKE3
+func Compute(data []byte, withBLAKE3 bool) Digests {
+	sum := sha256.Sum256(data)
+	digests := Digests{SHA256: sum[:]}
+	if withBLAKE3 {
+		sum := blake3.Sum256(data)
+		digests.BLAKE3 = sum[:]
+	}
+	return digests
+}
+
+// Hex returns the digests hex encoded by algorithm, as printed by sha256sum
+// and b3sum
+func (d Digests) Hex() map[string]string {
+	encoded := map[string]string{SHA256: hex.EncodeToString(d.SHA256)}
+	if d.BLAKE3 != nil {
+		encoded[BLAKE3] = hex.EncodeToString(d.BLAKE3)
+	}
+	return encoded
+}
+
+// ReprDigest formats the digests as an RFC 9530 Repr-Digest field value
+func (d Digests) ReprDigest() string {
+	value := SHA256 + "=:" + base64.StdEncoding.EncodeToString(d.SHA256) + ":"
+	if d.BLAKE3 != nil {
+		value += ", " + BLAKE3 + "=:" + base64.StdEncoding.EncodeToString(d.BLAKE3) + ":"
+	}
+	return value
+}
+
+// Digest formats the SHA-256 digest as an RFC 3230 Digest field value, for
+// clients that predate Repr-Digest
+func (d Digests) Digest() string {
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(d.SHA256)
+}
+
+// Verify checks the digests against an expected value supplied by the
+// uploader: a Repr-Digest style "sha-256=:BASE64:" or "blake3=:BASE64:",
+// several of them separated by commas, or a bare hex SHA-256. Every digest
+// given must match.
+func (d Digests) Verify(expected string) error {
+	expected = strings.TrimSpace(expected)
+	if sum, err := hex.DecodeString(expected); err == nil && len(sum) == sha256.Size {
+		return d.check(SHA256, sum)
+	}
+
+	for _, member := range strings.Split(expected, ",") {
+		algorithm, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return ErrInvalidDigest
+		}
+		sum, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			return ErrInvalidDigest
+		}
+		if err := d.check(strings.ToLower(algorithm), sum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d Digests) check(algorithm string, sum []byte) error {
+	var actual []byte
+	switch algorithm {
+	case SHA256:
+		actual = d.SHA256
+	case BLAKE3:
+		actual = d.BLAKE3
+		if actual == nil {
+			return domain.NewError(domain.ErrInvalidInput, "BLAKE3 digests are not enabled on this server")
+		}
+	default:
+		return domain.NewError(domain.ErrInvalidInput, "unsupported digest algorithm "+algorithm)
+	}
+
+	if !bytes.Equal(actual, sum) {
+		return ErrMismatch
+	}
+	return nil
+}
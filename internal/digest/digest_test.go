@@ -0,0 +1,61 @@
+package digest
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompute(t *testing.T) {
+	data := []byte("hello")
+
+	digests := Compute(data, false)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hex.EncodeToString(digests.SHA256))
+	assert.Nil(t, digests.BLAKE3)
+	assert.Equal(t, map[string]string{SHA256: hex.EncodeToString(digests.SHA256)}, digests.Hex())
+	assert.Equal(t, "sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:", digests.ReprDigest())
+	assert.Equal(t, "SHA-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=", digests.Digest())
+
+	digests = Compute(data, true)
+	assert.Equal(t, "ea8f163db38682925e4491c5e58d4bb3506ef8c14eb78a86e908c5624a67200f", hex.EncodeToString(digests.BLAKE3))
+	assert.Len(t, digests.Hex(), 2)
+	assert.Contains(t, digests.ReprDigest(), ", blake3=:")
+}
+
+func TestVerify(t *testing.T) {
+	digests := Compute([]byte("hello"), true)
+	sha := base64.StdEncoding.EncodeToString(digests.SHA256)
+	b3 := base64.StdEncoding.EncodeToString(digests.BLAKE3)
+
+	tests := []struct {
+		name     string
+		expected string
+		err      error
+	}{
+		{"hex", "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824", nil},
+		{"repr digest", "sha-256=:" + sha + ":", nil},
+		{"both", "sha-256=:" + sha + ":, blake3=:" + b3 + ":", nil},
+		{"mismatch", "sha-256=:" + b3 + ":", ErrMismatch},
+		{"one of two mismatched", "sha-256=:" + sha + ":, blake3=:" + sha + ":", ErrMismatch},
+		{"hex mismatch", hex.EncodeToString(digests.BLAKE3), ErrMismatch},
+		{"malformed", "sha-256=" + sha, ErrInvalidDigest},
+		{"bad base64", "sha-256=:***:", ErrInvalidDigest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := digests.Verify(tt.expected)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+
+	// Unknown and disabled algorithms are refused rather than ignored
+	assert.Error(t, digests.Verify("md5=:"+sha+":"))
+	assert.Error(t, Compute([]byte("hello"), false).Verify("blake3=:"+b3+":"))
+}
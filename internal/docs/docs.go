@@ -0,0 +1,31 @@
+package docs
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// specJSON is the OpenAPI description of every route registered by the server
+//
+//go:embed openapi.json
+var specJSON []byte
+
+//go:embed docs.html
+var viewerHTML []byte
+
+// Spec returns the raw OpenAPI document
+func Spec() []byte {
+	return specJSON
+}
+
+// SpecHandler serves the OpenAPI document
+func SpecHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", specJSON)
+}
+
+// ViewerHandler serves the HTML page rendering the OpenAPI document
+func ViewerHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", viewerHTML)
+}
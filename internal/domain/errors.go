@@ -0,0 +1,42 @@
+package domain
+
+import "errors"
+
+// Error kinds shared across packages. Package-specific errors wrap one of
+// these so callers can classify them with errors.Is without knowing where
+// they came from.
+var (
+	ErrInvalidInput        = errors.New("invalid input")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrNotFound            = errors.New("not found")
+	ErrExpired             = errors.New("expired")
+	ErrExhausted           = errors.New("download limit reached")
+	ErrInfected            = errors.New("infected")
+	ErrNotReady            = errors.New("not ready")
+	ErrTooLarge            = errors.New("too large")
+	ErrUnsupportedType     = errors.New("unsupported type")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrInsufficientStorage = errors.New("insufficient storage")
+	ErrThrottled           = errors.New("too many requests")
+	ErrConflict            = errors.New("conflict")
+)
+
+// Error is an error of a given kind with a message safe to show to clients
+type Error struct {
+	Kind    error
+	Message string
+}
+
+// NewError creates a new error of the given kind
+func NewError(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
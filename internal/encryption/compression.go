@@ -0,0 +1,107 @@
+package encryption
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies how a file was compressed before encryption. It is
+// recorded in the encrypted file's header.
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+var ErrUnknownCompression = errors.New("unknown compression")
+
+// ParseCompression parses a compression name, empty meaning none
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "", "none":
+		return CompressionNone, nil
+	case "gzip":
+		return CompressionGzip, nil
+	case "zstd":
+		return CompressionZstd, nil
+	default:
+		return CompressionNone, fmt.Errorf("%w %q", ErrUnknownCompression, name)
+	}
+}
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("compression(%d)", byte(c))
+	}
+}
+
+// zstdEncoder is shared by all uploads, EncodeAll is safe for concurrent use
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+})
+
+// compress compresses data, returning it unchanged with CompressionNone
+// when compression does not make it smaller
+func compress(data []byte, compression Compression) ([]byte, Compression, error) {
+	var compressed []byte
+	switch compression {
+	case CompressionNone:
+		return data, CompressionNone, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, CompressionNone, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, CompressionNone, err
+		}
+		compressed = buf.Bytes()
+	case CompressionZstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, CompressionNone, err
+		}
+		compressed = encoder.EncodeAll(data, make([]byte, 0, len(data)/2))
+	default:
+		return nil, CompressionNone, ErrUnknownCompression
+	}
+
+	if len(compressed) >= len(data) {
+		return data, CompressionNone, nil
+	}
+	return compressed, compression, nil
+}
+
+// decompressor returns a reader decompressing r
+func decompressor(r io.Reader, compression Compression) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, ErrUnknownCompression
+	}
+}
@@ -0,0 +1,26 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ConvergentKey derives a file's key from its content and a server secret,
+// so identical files get identical keys and can share one stored blob. The
+// returned blob ID names that blob. Anyone holding the secret can tell
+// whether two shares hold the same file, which is why this is opt-in.
+func ConvergentKey(secret, data []byte) (key []byte, blobID string) {
+	digest := sha256.Sum256(data)
+	return convergentMAC(secret, "shreadbox convergent key", digest[:]),
+		hex.EncodeToString(convergentMAC(secret, "shreadbox convergent blob", digest[:]))
+}
+
+// convergentMAC separates the key from the blob ID, so publishing one
+// reveals nothing about the other
+func convergentMAC(secret []byte, label string, digest []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(label))
+	mac.Write(digest)
+	return mac.Sum(nil)
+}
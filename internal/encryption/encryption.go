@@ -1,9 +1,11 @@
 package encryption
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"io"
 )
@@ -11,12 +13,23 @@ import (
 const (
 	// KeySize is the size of the encryption key in bytes (32 bytes = 256 bits)
 	KeySize = 32
+
+	// HeaderSize is the size of the header stored ahead of the ciphertext:
+	// magic | compression | nonce
+	HeaderSize = 4 + 1 + nonceSize
+
+	// Overhead is the number of bytes encryption adds to a file
+	Overhead = HeaderSize + 16
+
+	fileMagic = "SBF1"
+	nonceSize = 12
 )
 
 var (
 	ErrInvalidKeySize = errors.New("invalid key size: key must be 32 bytes")
 	ErrEncryption     = errors.New("encryption failed")
 	ErrDecryption     = errors.New("decryption failed")
+	ErrInvalidHeader  = errors.New("invalid encrypted file header")
 )
 
 // GenerateKey generates a new random 32-byte key
@@ -31,66 +44,113 @@ func GenerateKey() ([]byte, error) {
 
 // Encrypt encrypts data using AES-GCM
 func Encrypt(data []byte, key []byte) ([]byte, error) {
-	if len(key) != KeySize {
-		return nil, ErrInvalidKeySize
-	}
+	return EncryptCompressed(data, key, CompressionNone)
+}
 
-	// Create cipher block
-	block, err := aes.NewCipher(key)
+// EncryptCompressed compresses data before encrypting it with AES-GCM. The
+// compression actually used is recorded in the authenticated header; data
+// that does not shrink is stored uncompressed.
+func EncryptCompressed(data []byte, key []byte, compression Compression) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err == ErrInvalidKeySize {
+		return nil, err
+	}
 	if err != nil {
 		return nil, ErrEncryption
 	}
 
-	// Create GCM cipher mode
-	gcm, err := cipher.NewGCM(block)
+	data, compression, err = compress(data, compression)
 	if err != nil {
 		return nil, ErrEncryption
 	}
 
 	// Generate nonce
-	nonce := make([]byte, gcm.NonceSize())
+	header := make([]byte, HeaderSize, HeaderSize+len(data)+gcm.Overhead())
+	copy(header, fileMagic)
+	header[len(fileMagic)] = byte(compression)
+	nonce := header[len(fileMagic)+1:]
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, ErrEncryption
 	}
 
-	// Encrypt and seal data
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
+	// Encrypt and seal data, authenticating the header with it
+	return gcm.Seal(header, nonce, data, header), nil
 }
 
-// Decrypt decrypts data using AES-GCM
+// Decrypt decrypts data using AES-GCM, decompressing it if needed
 func Decrypt(data []byte, key []byte) ([]byte, error) {
-	if len(key) != KeySize {
-		return nil, ErrInvalidKeySize
+	reader, err := DecryptReader(data, key)
+	if err != nil {
+		return nil, err
 	}
+	defer reader.Close()
 
-	// Create cipher block
-	block, err := aes.NewCipher(key)
+	plaintext, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, ErrDecryption
 	}
+	return plaintext, nil
+}
 
-	// Create GCM cipher mode
-	gcm, err := cipher.NewGCM(block)
+// DecryptReader decrypts data using AES-GCM and returns a reader of the
+// plaintext, decompressing it as it is read
+func DecryptReader(data []byte, key []byte) (io.ReadCloser, error) {
+	gcm, err := newGCM(key)
+	if err == ErrInvalidKeySize {
+		return nil, err
+	}
 	if err != nil {
 		return nil, ErrDecryption
 	}
 
-	// Extract nonce from ciphertext
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
+	compression, err := ParseHeader(data)
+	if err != nil {
 		return nil, ErrDecryption
 	}
-
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	header := data[:HeaderSize]
+	nonce := header[len(fileMagic)+1:]
 
 	// Decrypt data
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, data[HeaderSize:], header)
 	if err != nil {
 		return nil, ErrDecryption
 	}
 
-	return plaintext, nil
+	reader, err := decompressor(bytes.NewReader(plaintext), compression)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return reader, nil
+}
+
+// ParseHeader checks the header of an encrypted file and returns how the
+// file was compressed
+func ParseHeader(data []byte) (Compression, error) {
+	if len(data) < HeaderSize || string(data[:len(fileMagic)]) != fileMagic {
+		return CompressionNone, ErrInvalidHeader
+	}
+
+	compression := Compression(data[len(fileMagic)])
+	if compression > CompressionZstd {
+		return CompressionNone, ErrInvalidHeader
+	}
+	return compression, nil
+}
+
+// newGCM creates an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKeySize
+	}
+
+	// Create cipher block
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create GCM cipher mode
+	return cipher.NewGCM(block)
 }
 
 // EncryptFile encrypts a file's contents
@@ -114,3 +174,24 @@ func EncryptFile(fileData []byte) ([]byte, []byte, error) {
 func DecryptFile(encryptedData []byte, key []byte) ([]byte, error) {
 	return Decrypt(encryptedData, key)
 }
+
+// EncryptJSON encrypts the JSON encoding of v
+func EncryptJSON(v any, key []byte) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, ErrEncryption
+	}
+	return Encrypt(data, key)
+}
+
+// DecryptJSON decrypts data encrypted by EncryptJSON into v
+func DecryptJSON(data []byte, key []byte, v any) error {
+	plaintext, err := Decrypt(data, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(plaintext, v); err != nil {
+		return ErrDecryption
+	}
+	return nil
+}
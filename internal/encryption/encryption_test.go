@@ -2,8 +2,11 @@ package encryption
 
 import (
 	"bytes"
+	"crypto/rand"
+	"io"
 	"testing"
 
+	"filippo.io/age"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -132,3 +135,149 @@ func TestEncryptFile(t *testing.T) {
 		})
 	}
 }
+
+func TestRecipientEnvelope(t *testing.T) {
+	alice, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	bob, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	mallory, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	recipients, err := ParseRecipients(alice.Recipient().String() + ",\n" + bob.Recipient().String())
+	assert.NoError(t, err)
+	assert.Len(t, recipients, 2)
+
+	// Seal a file to both recipients
+	data := []byte("for alice and bob only")
+	encrypted, key, err := EncryptFile(data)
+	assert.NoError(t, err)
+	wrapped, err := WrapKey(key, recipients)
+	assert.NoError(t, err)
+	envelope := MarshalEnvelope(wrapped, encrypted)
+
+	// Either recipient can open it
+	for _, identity := range []*age.X25519Identity{alice, bob} {
+		opened, err := OpenEnvelope(envelope, identity)
+		assert.NoError(t, err)
+		assert.Equal(t, data, opened)
+	}
+
+	// Anyone else cannot
+	_, err = OpenEnvelope(envelope, mallory)
+	assert.ErrorIs(t, err, ErrNoIdentity)
+
+	// Corrupt envelopes are rejected
+	_, err = OpenEnvelope([]byte("SBX1\xff\xff\xff\xff"), alice)
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+	_, err = OpenEnvelope(encrypted, alice)
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+}
+
+func TestParseRecipients(t *testing.T) {
+	recipients, err := ParseRecipients("")
+	assert.NoError(t, err)
+	assert.Empty(t, recipients)
+
+	_, err = ParseRecipients("age1notakey")
+	assert.ErrorIs(t, err, ErrInvalidRecipient)
+}
+
+func TestConvergentKey(t *testing.T) {
+	secret := []byte("server secret")
+	data := []byte("build artifact")
+
+	// Identical content maps to the same key and blob
+	key, blobID := ConvergentKey(secret, data)
+	sameKey, sameBlob := ConvergentKey(secret, bytes.Clone(data))
+	assert.Len(t, key, KeySize)
+	assert.Equal(t, key, sameKey)
+	assert.Equal(t, blobID, sameBlob)
+	assert.NotEqual(t, key, []byte(blobID))
+
+	// Different content or a different secret does not
+	otherKey, otherBlob := ConvergentKey(secret, []byte("other artifact"))
+	assert.NotEqual(t, key, otherKey)
+	assert.NotEqual(t, blobID, otherBlob)
+	foreignKey, foreignBlob := ConvergentKey([]byte("another server"), data)
+	assert.NotEqual(t, key, foreignKey)
+	assert.NotEqual(t, blobID, foreignBlob)
+
+	// The derived key encrypts like any other
+	encrypted, err := Encrypt(data, key)
+	assert.NoError(t, err)
+	decrypted, err := Decrypt(encrypted, sameKey)
+	assert.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+}
+
+func TestEncryptCompressed(t *testing.T) {
+	key, err := GenerateKey()
+	assert.NoError(t, err)
+	text := bytes.Repeat([]byte("timestamp,level,message\n2030-01-02,info,ok\n"), 1000)
+	random := make([]byte, 4096)
+	_, err = rand.Read(random)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		compression Compression
+		recorded    Compression
+	}{
+		{"none", text, CompressionNone, CompressionNone},
+		{"gzip", text, CompressionGzip, CompressionGzip},
+		{"zstd", text, CompressionZstd, CompressionZstd},
+		{"incompressible", random, CompressionZstd, CompressionNone},
+		{"empty", nil, CompressionGzip, CompressionNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := EncryptCompressed(tt.data, key, tt.compression)
+			assert.NoError(t, err)
+
+			// The compression used is recorded in the header
+			recorded, err := ParseHeader(encrypted)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.recorded, recorded)
+			if tt.recorded != CompressionNone {
+				assert.Less(t, len(encrypted), len(tt.data)/5)
+			}
+
+			// Decryption streams the decompressed plaintext
+			reader, err := DecryptReader(encrypted, key)
+			assert.NoError(t, err)
+			plaintext, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.NoError(t, reader.Close())
+			assert.Equal(t, len(tt.data), len(plaintext))
+			assert.True(t, bytes.Equal(tt.data, plaintext))
+		})
+	}
+
+	// The header is authenticated with the ciphertext
+	encrypted, err := EncryptCompressed(text, key, CompressionGzip)
+	assert.NoError(t, err)
+	encrypted[4] = byte(CompressionZstd)
+	_, err = Decrypt(encrypted, key)
+	assert.ErrorIs(t, err, ErrDecryption)
+
+	_, err = ParseHeader([]byte("not an encrypted file"))
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestParseCompression(t *testing.T) {
+	for _, name := range []string{"none", "gzip", "zstd"} {
+		compression, err := ParseCompression(name)
+		assert.NoError(t, err)
+		assert.Equal(t, name, compression.String())
+	}
+
+	compression, err := ParseCompression("")
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionNone, compression)
+
+	_, err = ParseCompression("brotli")
+	assert.ErrorIs(t, err, ErrUnknownCompression)
+}
@@ -0,0 +1,107 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// EnvelopeMagic starts every sealed envelope
+const EnvelopeMagic = "SBX1"
+
+// EnvelopeContentType is served for downloads of recipient-bound shares
+const EnvelopeContentType = "application/vnd.shreadbox.envelope"
+
+var (
+	ErrInvalidRecipient = errors.New("invalid recipient public key")
+	ErrInvalidEnvelope  = errors.New("invalid envelope")
+	ErrNoIdentity       = errors.New("no identity matches the envelope's recipients")
+)
+
+// ParseRecipients parses age X25519 public keys ("age1..."), separated by
+// commas or whitespace
+func ParseRecipients(list string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, field := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	}) {
+		recipient, err := age.ParseX25519Recipient(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRecipient, field)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// WrapKey encrypts a data key to every recipient. Any one recipient's
+// private key unwraps it; the server cannot.
+func WrapKey(key []byte, recipients []age.Recipient) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap key: %w", err)
+	}
+	if _, err := w.Write(key); err != nil {
+		return nil, fmt.Errorf("failed to wrap key: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to wrap key: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnwrapKey recovers a data key with one of the recipient's identities
+func UnwrapKey(wrapped []byte, identities ...age.Identity) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(wrapped), identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, ErrNoIdentity
+		}
+		return nil, fmt.Errorf("failed to unwrap key: %w", err)
+	}
+
+	key, err := io.ReadAll(io.LimitReader(r, KeySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, ErrInvalidKeySize
+	}
+	return key, nil
+}
+
+// MarshalEnvelope bundles a wrapped key with the encrypted file:
+// magic | uint32 wrapped key length | wrapped key | ciphertext
+func MarshalEnvelope(wrappedKey, ciphertext []byte) []byte {
+	envelope := make([]byte, 0, len(EnvelopeMagic)+4+len(wrappedKey)+len(ciphertext))
+	envelope = append(envelope, EnvelopeMagic...)
+	envelope = binary.BigEndian.AppendUint32(envelope, uint32(len(wrappedKey)))
+	envelope = append(envelope, wrappedKey...)
+	return append(envelope, ciphertext...)
+}
+
+// OpenEnvelope decrypts an envelope with one of the recipient's identities
+func OpenEnvelope(envelope []byte, identities ...age.Identity) ([]byte, error) {
+	header := len(EnvelopeMagic) + 4
+	if len(envelope) < header || string(envelope[:len(EnvelopeMagic)]) != EnvelopeMagic {
+		return nil, ErrInvalidEnvelope
+	}
+
+	keyLen := int(binary.BigEndian.Uint32(envelope[len(EnvelopeMagic):header]))
+	if keyLen > len(envelope)-header {
+		return nil, ErrInvalidEnvelope
+	}
+
+	key, err := UnwrapKey(envelope[header:header+keyLen], identities...)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptFile(envelope[header+keyLen:], key)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// AdminStats returns aggregate storage statistics
+func (h *Handler) AdminStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.storage.Stats())
+}
+
+// AdminReconcileReport returns the report of the last storage reconciliation
+func (h *Handler) AdminReconcileReport(c *gin.Context) {
+	report, ok := h.storage.LastReconcile()
+	if !ok {
+		c.Error(domain.NewError(domain.ErrNotFound, "no reconciliation has run yet"))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// AdminReconcile reconciles the storage directory now. The report is
+// returned even if some problems could not be fixed.
+func (h *Handler) AdminReconcile(c *gin.Context) {
+	report, _ := h.storage.Reconcile()
+	c.JSON(http.StatusOK, report)
+}
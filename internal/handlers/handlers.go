@@ -1,56 +1,157 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"path/filepath"
+	"net/netip"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"filippo.io/age"
 	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/access"
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/hardiksharma/shreadbox/internal/contenttype"
+	"github.com/hardiksharma/shreadbox/internal/digest"
+	"github.com/hardiksharma/shreadbox/internal/domain"
 	"github.com/hardiksharma/shreadbox/internal/encryption"
+	"github.com/hardiksharma/shreadbox/internal/mail"
+	"github.com/hardiksharma/shreadbox/internal/middleware"
+	"github.com/hardiksharma/shreadbox/internal/preview"
+	"github.com/hardiksharma/shreadbox/internal/quota"
+	"github.com/hardiksharma/shreadbox/internal/scan"
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
 	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/hardiksharma/shreadbox/internal/webhook"
 )
 
 // Handler represents the HTTP handler
 type Handler struct {
 	storage *storage.Storage
+	quotas  *quota.Manager
+	opts    Options
+}
+
+// Options configures upload handling
+type Options struct {
+	MaxFileSize int64 // in bytes
+	TypePolicy  *contenttype.Policy
+	Scanner     scan.Scanner // nil disables malware scanning
+	ScanTimeout time.Duration
+	Mail        *mail.Notifier         // nil disables email notifications
+	LinkSecret  []byte                 // signs download confirmation nonces, random if empty
+	DedupSecret []byte                 // derives convergent keys for deduplicated uploads, nil disables them
+	Compression encryption.Compression // applied before encryption when it helps
+	BLAKE3      bool                   // adds BLAKE3 to the SHA-256 digest of each upload
+	Tokens      *sharetoken.Limiter    // throttles clients guessing share tokens, nil disables
+	BaseURL     string                 // public URL of the server, prefixes links in QR codes
+}
+
+// shareOptions describes how saveShare stores a share
+type shareOptions struct {
+	recipientKeys []age.Recipient // seal the share to these keys
+	dedup         bool            // share a blob with identical files
+	digests       digest.Digests  // of the plaintext, stored encrypted with the share
+	previews      int             // how often recipients may preview the share, if it can be
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(storage *storage.Storage) *Handler {
+func NewHandler(storage *storage.Storage, quotas *quota.Manager, opts Options) *Handler {
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	if len(opts.LinkSecret) == 0 {
+		opts.LinkSecret = make([]byte, 32)
+		if _, err := rand.Read(opts.LinkSecret); err != nil {
+			panic("failed to generate link secret: " + err.Error())
+		}
+	}
+
 	return &Handler{
 		storage: storage,
+		quotas:  quotas,
+		opts:    opts,
 	}
 }
 
+// maxRecipientKeys caps how many public keys a share may be sealed to
+const maxRecipientKeys = 16
+
+// maxPreviews caps how often a share may be previewed
+const maxPreviews = 20
+
+var (
+	errDedupDisabled = domain.NewError(domain.ErrInvalidInput, "deduplication is not enabled on this server")
+	errDedupSealed   = domain.NewError(domain.ErrInvalidInput, "shares sealed to recipient keys cannot be deduplicated")
+	errAliasNoKey    = domain.NewError(domain.ErrUnauthorized, "custom aliases require an API key")
+	errMailNoKey     = domain.NewError(domain.ErrForbidden, "recipients and notify_email require an API key")
+	errPreviewCount  = domain.NewError(domain.ErrInvalidInput, fmt.Sprintf("previews_allowed must be between 0 and %d", maxPreviews))
+	errPreviewSealed = domain.NewError(domain.ErrInvalidInput, "shares sealed to recipient keys cannot have previews")
+	errPreviewOnce   = domain.NewError(domain.ErrInvalidInput, "one-time shares cannot have previews, allow more than one download")
+)
+
+// clientIdentity returns the identity uploads are accounted to, the API
+// key when the request is authenticated and the client IP otherwise
+func clientIdentity(c *gin.Context) string {
+	if key := middleware.APIKey(c); key != nil {
+		return "key:" + key.ID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// resolveToken returns the ID of the share a token links to. Clients
+// looking up too many unknown tokens are turned away for a while.
+func (h *Handler) resolveToken(c *gin.Context, token string) (string, error) {
+	if err := h.opts.Tokens.Allow(c.ClientIP()); err != nil {
+		return "", err
+	}
+	id, err := h.storage.Lookup(token)
+	if err != nil {
+		h.opts.Tokens.Fail(c.ClientIP())
+	}
+	return id, err
+}
+
 // Upload handles file upload requests
 func (h *Handler) Upload(c *gin.Context) {
-	// Get file from request
-	file, header, err := c.Request.FormFile("file")
+	// Apply the API key's policy, if any
+	var policy auth.Policy
+	if key := middleware.APIKey(c); key != nil {
+		policy = key.Policy
+	}
+
+	// Keys may carry their own size allowance instead of the server default
+	maxSize := h.opts.MaxFileSize
+	if policy.MaxFileSize > 0 {
+		maxSize = policy.MaxFileSize
+	}
+
+	// Stream the multipart body, rejecting oversize files early
+	form, err := readUploadForm(c.Writer, c.Request, maxSize)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		c.Error(err)
 		return
 	}
-	defer file.Close()
+	fileData := form.Data
 
-	// Read file data
-	fileData := make([]byte, header.Size)
-	if _, err := file.Read(fileData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+	// Detect the type from the content itself, the client's header is ignored
+	contentType := contenttype.Detect(form.FileName, fileData)
+	if err := h.opts.TypePolicy.Check(form.FileName, contentType); err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Parse form parameters
-	expiryTime := c.PostForm("expiry_time")
-	downloadsStr := c.PostForm("downloads_allowed")
-	message := c.PostForm("message")
+	expiryTime := form.Fields["expiry_time"]
+	downloadsStr := form.Fields["downloads_allowed"]
+	message := form.Fields["message"]
+	notifyURL := form.Fields["notify_url"]
 
-	// Parse expiry time
-	duration, err := time.ParseDuration(expiryTime)
-	if err != nil {
-		duration = 24 * time.Hour // Default to 24 hours
-	}
+	// Parse expiry time, defaulting to 24 hours
+	duration := parseExpiry(expiryTime, 24*time.Hour, policy.MaxExpiry)
 
 	// Parse downloads allowed
 	downloads, err := strconv.Atoi(downloadsStr)
@@ -58,91 +159,438 @@ func (h *Handler) Upload(c *gin.Context) {
 		downloads = 1 // Default to 1 download
 	}
 
-	// Encrypt file
-	encryptedData, key, err := encryption.EncryptFile(fileData)
+	if policy.MaxExpiry > 0 && duration > policy.MaxExpiry {
+		c.Error(domain.NewError(domain.ErrInvalidInput, "expiry exceeds the maximum allowed for this key"))
+		return
+	}
+	if policy.MaxDownloads > 0 && downloads > policy.MaxDownloads {
+		c.Error(domain.NewError(domain.ErrInvalidInput, "downloads exceed the maximum allowed for this key"))
+		return
+	}
+
+	// Events for this share are signed with a secret only the uploader sees
+	var notifySecret string
+	if notifyURL != "" {
+		if err := webhook.ValidateURL(notifyURL); err != nil {
+			c.Error(err)
+			return
+		}
+		if notifySecret, err = webhook.NewSecret(); err != nil {
+			c.Error(fmt.Errorf("failed to generate notify secret: %w", err))
+			return
+		}
+	}
+
+	// Recipients are emailed the link, the uploader hears about downloads.
+	// Anonymous uploaders could otherwise have the server mail anyone.
+	if (form.Fields["recipients"] != "" || form.Fields["notify_email"] != "") && middleware.APIKey(c) == nil {
+		c.Error(errMailNoKey)
+		return
+	}
+	recipients, err := mail.ParseAddresses(form.Fields["recipients"])
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Encryption failed"})
+		c.Error(err)
+		return
+	}
+	uploaderEmail := form.Fields["notify_email"]
+	if uploaderEmail != "" {
+		addresses, err := mail.ParseAddresses(uploaderEmail)
+		if err != nil || len(addresses) != 1 {
+			c.Error(mail.ErrInvalidAddress)
+			return
+		}
+		uploaderEmail = addresses[0]
+	}
+	if (len(recipients) > 0 || uploaderEmail != "") && h.opts.Mail == nil {
+		c.Error(mail.ErrMailUnavailable)
+		return
+	}
+
+	// Shares sealed to public keys can only be opened by those recipients
+	recipientKeys, err := parseRecipientKeys(form.Fields["recipient_keys"])
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Identical files uploaded with dedup share one stored blob
+	dedup := form.Fields["dedup"] == "true"
+	if dedup && h.opts.DedupSecret == nil {
+		c.Error(errDedupDisabled)
+		return
+	}
+	if dedup && len(recipientKeys) > 0 {
+		c.Error(errDedupSealed)
+		return
+	}
+
+	// Previews are off unless the uploader allows them, and never offered
+	// for one-time shares
+	previews := 0
+	if value := form.Fields["previews_allowed"]; value != "" {
+		if previews, err = strconv.Atoi(value); err != nil || previews < 0 || previews > maxPreviews {
+			c.Error(errPreviewCount)
+			return
+		}
+	}
+	if previews > 0 && len(recipientKeys) > 0 {
+		c.Error(errPreviewSealed)
+		return
+	}
+	if previews > 0 && downloads == 1 {
+		c.Error(errPreviewOnce)
+		return
+	}
+
+	// Authenticated uploaders may choose the token of the link
+	alias := form.Fields["alias"]
+	if alias != "" {
+		if middleware.APIKey(c) == nil {
+			c.Error(errAliasNoKey)
+			return
+		}
+		if err := sharetoken.ValidateAlias(alias); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	// Uploaders may supply a digest the file must match
+	digests, err := h.digestUpload(fileData, form.Fields["expected_digest"])
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Access rules are checked on every download attempt
+	accessPolicy, err := access.Parse(access.Rules{
+		AllowedIPs: form.Fields["allowed_ips"],
+		NotBefore:  form.Fields["not_before"],
+		Windows:    form.Fields["access_windows"],
+		Timezone:   form.Fields["access_timezone"],
+		MaxClients: form.Fields["max_clients"],
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Create metadata
 	metadata := &storage.FileMetadata{
-		FileName:      header.Filename,
-		EncryptionKey: key,
+		Token:         alias,
+		FileName:      form.FileName,
 		ExpiresAt:     time.Now().Add(duration),
 		DownloadsLeft: downloads,
 		Message:       message,
-		ContentType:   header.Header.Get("Content-Type"),
-		FileSize:      header.Size,
+		ContentType:   contentType,
+		Owner:         clientIdentity(c),
+		NotifyURL:     notifyURL,
+		NotifySecret:  notifySecret,
+		Recipients:    recipients,
+		UploaderEmail: uploaderEmail,
+		Access:        accessPolicy,
 	}
-
-	// Save file
-	if err := h.storage.SaveFile(encryptedData, metadata); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	if err := h.saveShare(fileData, metadata, shareOptions{recipientKeys: recipientKeys, dedup: dedup, digests: digests, previews: previews}); err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Generate download URL
-	downloadURL := fmt.Sprintf("/api/download/%s", metadata.ID)
+	downloadURL := "/d/" + url.PathEscape(metadata.Token)
 
 	// Return response
 	c.JSON(http.StatusOK, storage.FileUploadResponse{
-		Token:       metadata.ID,
-		ExpiresAt:   metadata.ExpiresAt,
-		FileName:    metadata.FileName,
-		DownloadURL: downloadURL,
+		ID:              metadata.ID,
+		Token:           metadata.Token,
+		ExpiresAt:       metadata.ExpiresAt,
+		FileName:        metadata.FileName,
+		DownloadURL:     downloadURL,
+		ManagementToken: metadata.ManageToken,
+		NotifySecret:    notifySecret,
+		Digests:         digests.Hex(),
+		Previews:        metadata.PreviewsLeft,
 	})
 }
 
-// Download handles file download requests
-func (h *Handler) Download(c *gin.Context) {
-	// Get file ID from URL
-	fileID := c.Param("token")
+// parseExpiry parses an expiry chosen by the client. Unset or invalid
+// values get fallback, capped at the key's maximum if it has one, so keys
+// with a short limit need not spell out an expiry on every upload.
+func parseExpiry(value string, fallback, max time.Duration) time.Duration {
+	if expiry, err := time.ParseDuration(value); err == nil && expiry > 0 {
+		return expiry
+	}
+	if max > 0 && fallback > max {
+		return max
+	}
+	return fallback
+}
 
-	// Get file metadata
-	metadata, err := h.storage.GetFile(fileID)
+// parseRecipientKeys parses the age public keys a share is sealed to
+func parseRecipientKeys(list string) ([]age.Recipient, error) {
+	recipientKeys, err := encryption.ParseRecipients(list)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found or expired"})
-		return
+		return nil, domain.NewError(domain.ErrInvalidInput, err.Error())
 	}
+	if len(recipientKeys) > maxRecipientKeys {
+		return nil, domain.NewError(domain.ErrInvalidInput, fmt.Sprintf("at most %d recipient keys are allowed", maxRecipientKeys))
+	}
+	return recipientKeys, nil
+}
 
-	// Read encrypted file
-	encryptedData, err := h.storage.ReadFile(metadata)
+// digestUpload computes the digests of an uploaded file and checks them
+// against the expected digest, if one was supplied
+func (h *Handler) digestUpload(data []byte, expected string) (digest.Digests, error) {
+	digests := digest.Compute(data, h.opts.BLAKE3)
+	if expected != "" {
+		if err := digests.Verify(expected); err != nil {
+			return digest.Digests{}, err
+		}
+	}
+	return digests, nil
+}
+
+// saveShare encrypts data and stores it as the share described by metadata,
+// charging it to metadata.Owner. With recipient keys the data key is sealed
+// to them and discarded, so stored shares can never be decrypted server side.
+// With dedup the key is derived from the content, so identical files share
+// one blob; each share is still charged for the full size.
+func (h *Handler) saveShare(data []byte, metadata *storage.FileMetadata, opts shareOptions) error {
+	var key []byte
+	var err error
+	if opts.dedup {
+		key, metadata.BlobID = encryption.ConvergentKey(h.opts.DedupSecret, data)
+	} else if key, err = encryption.GenerateKey(); err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	// Compress first unless the format is compressed already; quotas are
+	// charged for what is stored
+	compression := h.opts.Compression
+	if contenttype.Compressed(metadata.ContentType) {
+		compression = encryption.CompressionNone
+	}
+	encryptedData, err := encryption.EncryptCompressed(data, key, compression)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-		return
+		return fmt.Errorf("failed to encrypt file: %w", err)
+	}
+
+	// Digests are only readable with the data key, like the file itself
+	if metadata.Digests, err = encryption.EncryptJSON(opts.digests, key); err != nil {
+		return fmt.Errorf("failed to encrypt digests: %w", err)
 	}
 
-	// Decrypt file
-	decryptedData, err := encryption.DecryptFile(encryptedData, metadata.EncryptionKey)
+	// So are previews, made now while the plaintext is at hand
+	if opts.previews > 0 && preview.Supported(metadata.ContentType) {
+		if err := encryptPreview(data, key, metadata); err != nil {
+			log.Printf("No preview for %s: %v", metadata.FileName, err)
+		} else {
+			metadata.PreviewsLeft = opts.previews
+		}
+	}
+
+	if len(opts.recipientKeys) > 0 {
+		if metadata.WrappedKey, err = encryption.WrapKey(key, opts.recipientKeys); err != nil {
+			return err
+		}
+		key = nil
+	}
+	metadata.EncryptionKey = key
+	metadata.FileSize = int64(len(data))
+
+	// Reserve quota before anything is written to disk. Deduplicated
+	// shares may end up referencing a blob of another size, so the share
+	// records what was reserved and exactly that is released.
+	metadata.QuotaSize = int64(len(encryptedData))
+	if err := h.quotas.Reserve(metadata.Owner, metadata.QuotaSize); err != nil {
+		return err
+	}
+
+	// Hold the share back until the scanner has cleared it
+	if h.opts.Scanner != nil {
+		metadata.ScanStatus = storage.ScanPending
+	}
+
+	if err := h.storage.SaveFile(encryptedData, metadata); err != nil {
+		h.quotas.Release(metadata.Owner, metadata.QuotaSize)
+		if errors.Is(err, storage.ErrTokenTaken) {
+			return err
+		}
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+
+	// Recipients are emailed once the share can be downloaded
+	if h.opts.Scanner != nil {
+		go h.scanFile(metadata.ID, metadata.Token, data)
+	} else if h.opts.Mail != nil {
+		h.opts.Mail.ShareReady(*metadata)
+	}
+	return nil
+}
+
+// encryptPreview makes the preview of a file and sets it on metadata,
+// encrypted with the file's key
+func encryptPreview(data, key []byte, metadata *storage.FileMetadata) error {
+	content, contentType, err := preview.Generate(metadata.ContentType, data)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Decryption failed"})
+		return err
+	}
+	if metadata.Preview, err = encryption.Encrypt(content, key); err != nil {
+		return fmt.Errorf("failed to encrypt preview: %w", err)
+	}
+	metadata.PreviewType = contentType
+	return nil
+}
+
+// Download handles file download requests from scripts. Browsers and link
+// preview bots, which do not send the client header, are sent to the
+// confirmation page so merely fetching a link never consumes a download.
+func (h *Handler) Download(c *gin.Context) {
+	token := c.Param("token")
+
+	if c.GetHeader(ClientHeader) == "" {
+		c.Redirect(http.StatusSeeOther, "/d/"+url.PathEscape(token))
 		return
 	}
 
-	// Set response headers
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(metadata.FileName)))
-	c.Header("Content-Type", metadata.ContentType)
-	c.Header("Content-Length", strconv.FormatInt(metadata.FileSize, 10))
+	if err := h.serveDownload(c, token); err != nil {
+		c.Error(err)
+	}
+}
+
+// serveDownload counts a download of the share token links to and sends
+// the file
+func (h *Handler) serveDownload(c *gin.Context, token string) error {
+	fileID, err := h.resolveToken(c, token)
+	if err != nil {
+		return err
+	}
+
+	// Enforce the share's access rules before a download is counted
+	clientIP, err := netip.ParseAddr(c.ClientIP())
+	if err != nil {
+		return fmt.Errorf("failed to parse client address: %w", err)
+	}
+	if err := h.storage.AuthorizeAccess(fileID, clientIP); err != nil {
+		return err
+	}
+
+	return h.sendShare(c, fileID)
+}
+
+// sendShare counts a download of a share and sends the file, sealed shares
+// as envelopes
+func (h *Handler) sendShare(c *gin.Context, fileID string) error {
+	// Count the download, taking the encrypted file with it
+	metadata, encryptedData, err := h.storage.GetFile(fileID)
+	if err != nil {
+		return err
+	}
+
+	// Sealed shares are relayed as envelopes only recipients can open
+	if len(metadata.WrappedKey) > 0 {
+		envelope := encryption.MarshalEnvelope(metadata.WrappedKey, encryptedData)
+		c.Header("Content-Disposition", contenttype.ContentDisposition("attachment", metadata.FileName+".sbx"))
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Data(http.StatusOK, encryption.EnvelopeContentType, envelope)
+		return nil
+	}
+
+	// Decrypt file, decompressing it as it is sent
+	plaintext, err := encryption.DecryptReader(encryptedData, metadata.EncryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt file: %w", err)
+	}
+	defer plaintext.Close()
+
+	// Let recipients verify the file is exactly what was uploaded
+	var digests digest.Digests
+	if err := encryption.DecryptJSON(metadata.Digests, metadata.EncryptionKey, &digests); err == nil && digests.SHA256 != nil {
+		c.Header("Repr-Digest", digests.ReprDigest())
+		c.Header("Digest", digests.Digest())
+	}
+
+	// Set response headers. Files are always attachments and sandboxed so
+	// shared HTML or SVG can never run scripts in our origin.
+	c.Header("Content-Disposition", contenttype.ContentDisposition("attachment", metadata.FileName))
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Header("X-Content-Type-Options", "nosniff")
 
 	// Send file
-	c.Data(http.StatusOK, metadata.ContentType, decryptedData)
+	c.DataFromReader(http.StatusOK, metadata.FileSize, metadata.ContentType, plaintext, nil)
+	return nil
 }
 
 // Status handles file status requests
 func (h *Handler) Status(c *gin.Context) {
-	fileID := c.Param("token")
+	fileID, err := h.resolveToken(c, c.Param("token"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	metadata, err := h.storage.GetFileMetadata(fileID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"file_name":      metadata.FileName,
 		"expires_at":     metadata.ExpiresAt,
 		"downloads_left": metadata.DownloadsLeft,
 		"message":        metadata.Message,
-	})
+	}
+	if metadata.ScanStatus != "" {
+		response["scan_status"] = metadata.ScanStatus
+	}
+	if len(metadata.WrappedKey) > 0 {
+		response["sealed"] = true
+		response["content_type"] = metadata.ContentType
+	}
+	if metadata.PreviewPath != "" {
+		response["previews_left"] = metadata.PreviewsLeft
+	}
+
+	// Owners see who was turned away by the share's access rules
+	if key := middleware.APIKey(c); key != nil && clientIdentity(c) == metadata.Owner && metadata.Access != nil {
+		response["access_denials"] = metadata.AccessDenials
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// MyShares lists the active shares uploaded with the caller's API key
+func (h *Handler) MyShares(c *gin.Context) {
+	shares := h.storage.ListByOwner(clientIdentity(c))
+
+	response := make([]gin.H, 0, len(shares))
+	for _, metadata := range shares {
+		response = append(response, shareSummary(metadata))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": response})
+}
+
+// shareSummary describes a share in listings
+func shareSummary(metadata storage.FileMetadata) gin.H {
+	return gin.H{
+		"id":             metadata.ID,
+		"file_name":      metadata.FileName,
+		"file_size":      metadata.FileSize,
+		"expires_at":     metadata.ExpiresAt,
+		"downloads_left": metadata.DownloadsLeft,
+		"scan_status":    metadata.ScanStatus,
+		"created_at":     metadata.CreatedAt,
+	}
+}
+
+// RevokeShare destroys one of the caller's shares before it expires. Shares
+// are named by ID, as listed, since tokens are not kept after upload.
+func (h *Handler) RevokeShare(c *gin.Context) {
+	if err := h.storage.Revoke(c.Param("id"), clientIdentity(c)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/hardiksharma/shreadbox/internal/contenttype"
+	"github.com/hardiksharma/shreadbox/internal/digest"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+	"github.com/hardiksharma/shreadbox/internal/middleware"
+	"github.com/hardiksharma/shreadbox/internal/quota"
+	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer routes requests to a handler the way main does
+type testServer struct {
+	router  *gin.Engine
+	storage *storage.Storage
+	quotas  *quota.Manager
+	keys    *auth.KeyStore
+}
+
+func newTestServer(t *testing.T, opts Options) *testServer {
+	t.Helper()
+
+	store, err := storage.NewStorage(t.TempDir())
+	require.NoError(t, err)
+	keys, err := auth.NewKeyStore(filepath.Join(t.TempDir(), "apikeys.json"))
+	require.NoError(t, err)
+	if opts.MaxFileSize == 0 {
+		opts.MaxFileSize = 1 << 20
+	}
+	if opts.TypePolicy == nil {
+		opts.TypePolicy = contenttype.NewPolicy(nil, nil, nil, nil)
+	}
+	quotas := quota.NewManager(quota.Limits{})
+	store.Subscribe(func(event storage.Event) {
+		if event.Type.Removed() {
+			quotas.Release(event.File.Owner, event.File.QuotaSize)
+		}
+	})
+	h := NewHandler(store, quotas, opts)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler(false))
+	router.SetHTMLTemplate(templates(t))
+	api := router.Group("/api")
+	api.POST("/upload", middleware.Authenticate(keys, false), h.Upload)
+	api.GET("/download/:token", middleware.TokenRoute, h.Download)
+	api.GET("/status/:token", middleware.TokenRoute, middleware.Authenticate(keys, false), h.Status)
+	api.GET("/preview/:token", middleware.TokenRoute, h.Preview)
+	api.GET("/shares/:id", h.ManagedShare)
+	api.DELETE("/shares/:id", h.RevokeManagedShare)
+	api.POST("/requests", middleware.Authenticate(keys, true), h.CreateRequest)
+	api.GET("/requests/:id", h.GetRequest)
+	api.POST("/requests/:id/upload", h.UploadToRequest)
+	api.GET("/me/requests", middleware.Authenticate(keys, true), h.MyRequests)
+	api.GET("/me/requests/:id/files", middleware.Authenticate(keys, true), h.RequestFiles)
+	api.GET("/me/requests/:id/files/:file", middleware.Authenticate(keys, true), h.DownloadRequestFile)
+	api.DELETE("/me/requests/:id", middleware.Authenticate(keys, true), h.CloseRequest)
+	router.GET("/d/:token", middleware.TokenRoute, h.DownloadPage)
+	router.POST("/d/:token", middleware.TokenRoute, h.ConfirmDownload)
+
+	return &testServer{router: router, storage: store, quotas: quotas, keys: keys}
+}
+
+// apiKey issues a key with policy and returns its secret
+func (s *testServer) apiKey(t *testing.T, policy auth.Policy) string {
+	t.Helper()
+	secret, _, err := s.keys.Create("test", policy)
+	require.NoError(t, err)
+	return secret
+}
+
+// serve sends req, authenticated with the API key secret if one is given
+func (s *testServer) serve(req *http.Request, secret string) *httptest.ResponseRecorder {
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// upload posts a file with fields and decodes the upload response
+func (s *testServer) upload(t *testing.T, fields map[string]string, data []byte, secret string) storage.FileUploadResponse {
+	t.Helper()
+	w := s.serve(newMultipartRequest(t, fields, "hello.txt", data), secret)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response storage.FileUploadResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestUploadExpiryCappedByKey(t *testing.T) {
+	s := newTestServer(t, Options{})
+	secret := s.apiKey(t, auth.Policy{MaxExpiry: time.Hour})
+
+	// Uploads leaving the expiry unset get the key's maximum, not an error
+	share := s.upload(t, nil, []byte("hello"), secret)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), share.ExpiresAt, time.Minute)
+
+	share = s.upload(t, map[string]string{"expiry_time": "10m"}, []byte("hello"), secret)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), share.ExpiresAt, time.Minute)
+
+	// Asking for more than the key allows is still refused
+	w := s.serve(newMultipartRequest(t, map[string]string{"expiry_time": "2h"}, "hello.txt", []byte("hello")), secret)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Anonymous uploads keep the server default
+	share = s.upload(t, nil, []byte("hello"), "")
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), share.ExpiresAt, time.Minute)
+}
+
+// download fetches a share the way scripts do
+func (s *testServer) download(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/download/"+token, nil)
+	req.Header.Set(ClientHeader, "test")
+	return s.serve(req, "")
+}
+
+func TestUploadDownload(t *testing.T) {
+	s := newTestServer(t, Options{Compression: encryption.CompressionGzip})
+
+	// One-time shares, the default, are shredded by their download, which
+	// must not race the download reading them
+	for i := range 50 {
+		content := bytes.Repeat([]byte(fmt.Sprintf("line %d of a shared file\n", i)), 200)
+		share := s.upload(t, nil, content, "")
+
+		w := s.download(share.Token)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, content, w.Body.Bytes())
+		assert.Equal(t, "sha-256=:"+base64.StdEncoding.EncodeToString(digest.Compute(content, false).SHA256)+":", w.Header().Get("Repr-Digest"))
+
+		assert.Equal(t, http.StatusNotFound, s.download(share.Token).Code)
+	}
+	assert.Zero(t, s.storage.Stats().ActiveShares)
+
+	// Shares allowing several downloads serve each of them
+	share := s.upload(t, map[string]string{"downloads_allowed": "3"}, []byte("hello"), "")
+	for range 3 {
+		w := s.download(share.Token)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "hello", w.Body.String())
+	}
+	assert.Equal(t, http.StatusNotFound, s.download(share.Token).Code)
+}
+
+func TestUploadMailNeedsKey(t *testing.T) {
+	s := newTestServer(t, Options{})
+	secret := s.apiKey(t, auth.Policy{})
+
+	// Anonymous uploads cannot have the server email anyone
+	for _, fields := range []map[string]string{
+		{"recipients": "alice@example.com,bob@example.com"},
+		{"notify_email": "me@example.com"},
+	} {
+		w := s.serve(newMultipartRequest(t, fields, "hello.txt", []byte("hello")), "")
+		assert.Equal(t, http.StatusForbidden, w.Code, fields)
+
+		// Keys get past the check, to find this server has no SMTP
+		w = s.serve(newMultipartRequest(t, fields, "hello.txt", []byte("hello")), secret)
+		assert.Equal(t, http.StatusBadRequest, w.Code, fields)
+		assert.Contains(t, w.Body.String(), "not enabled", fields)
+	}
+	assert.Zero(t, s.storage.Stats().ActiveShares)
+}
+
+func TestDedupQuotaReleased(t *testing.T) {
+	s := newTestServer(t, Options{
+		Compression: encryption.CompressionGzip,
+		DedupSecret: []byte("dedup secret"),
+	})
+
+	// The same content is compressed as a .bin but not as a .zip, so the
+	// second share references a blob smaller than what it reserved
+	content := bytes.Repeat([]byte{0, 1, 2, 3}, 4096)
+	var shares []storage.FileUploadResponse
+	for _, name := range []string{"data.bin", "data.zip"} {
+		w := s.serve(newMultipartRequest(t, map[string]string{"dedup": "true"}, name, content), "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var share storage.FileUploadResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &share))
+		shares = append(shares, share)
+	}
+	assert.Equal(t, 2, s.quotas.Usage("ip:192.0.2.1").Shares)
+	assert.Greater(t, s.quotas.Total(), s.storage.Stats().TotalBytes)
+
+	// Removing the shares releases exactly what was reserved
+	for _, share := range shares {
+		req := httptest.NewRequest(http.MethodDelete, "/api/shares/"+share.ID, nil)
+		req.Header.Set(ManagementHeader, share.ManagementToken)
+		require.Equal(t, http.StatusNoContent, s.serve(req, "").Code)
+	}
+	assert.Zero(t, s.quotas.Total())
+	assert.Zero(t, s.quotas.Usage("ip:192.0.2.1").Bytes)
+}
+
+func TestStatusDuringDownloads(t *testing.T) {
+	s := newTestServer(t, Options{})
+	secret := s.apiKey(t, auth.Policy{})
+	share := s.upload(t, map[string]string{"downloads_allowed": "20", "allowed_ips": "10.0.0.0/8"}, []byte("hello"), secret)
+
+	// Downloads, allowed and refused, change the share while its owner
+	// reads its status
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/download/"+share.Token, nil)
+			req.Header.Set(ClientHeader, "test")
+			req.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i+1)
+			assert.Equal(t, http.StatusOK, s.serve(req, "").Code)
+		}()
+		go func() {
+			defer wg.Done()
+			// Refused, or not found once the last download shredded it
+			assert.Contains(t, []int{http.StatusForbidden, http.StatusNotFound}, s.download(share.Token).Code)
+		}()
+		go func() {
+			defer wg.Done()
+			s.serve(httptest.NewRequest(http.MethodGet, "/api/status/"+share.Token, nil), secret)
+		}()
+	}
+	wg.Wait()
+	assert.Zero(t, s.storage.Stats().ActiveShares)
+}
+
+func TestManagedShareAccessDenials(t *testing.T) {
+	s := newTestServer(t, Options{})
+	guarded := s.upload(t, map[string]string{"allowed_ips": "10.0.0.0/8"}, []byte("hello"), "")
+	open := s.upload(t, nil, []byte("hello"), "")
+
+	manage := func(share storage.FileUploadResponse) map[string]any {
+		req := httptest.NewRequest(http.MethodGet, "/api/shares/"+share.ID, nil)
+		req.Header.Set(ManagementHeader, share.ManagementToken)
+		w := s.serve(req, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	// Anonymous uploaders see refused attempts with their management token
+	assert.Empty(t, manage(guarded)["access_denials"])
+	assert.Equal(t, http.StatusForbidden, s.download(guarded.Token).Code)
+	denials := manage(guarded)["access_denials"].([]any)
+	require.Len(t, denials, 1)
+	assert.Equal(t, "192.0.2.1", denials[0].(map[string]any)["ip"])
+
+	// Shares without access rules have none to report
+	assert.NotContains(t, manage(open), "access_denials")
+}
+
+func TestUploadPreviews(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	// One-time shares never offer previews
+	w := s.serve(newMultipartRequest(t, map[string]string{"previews_allowed": "2"}, "notes.txt", []byte("hello")), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "one-time")
+	share := s.upload(t, nil, []byte("hello"), "")
+	assert.Zero(t, share.Previews)
+
+	// Views count against the previews, not the downloads
+	share = s.upload(t, map[string]string{"previews_allowed": "2", "downloads_allowed": "2"}, []byte("hello\nworld\n"), "")
+	assert.Equal(t, 2, share.Previews)
+	for range 2 {
+		w = s.serve(httptest.NewRequest(http.MethodGet, "/api/preview/"+share.Token, nil), "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "hello\nworld\n", w.Body.String())
+	}
+	assert.Equal(t, http.StatusGone, s.serve(httptest.NewRequest(http.MethodGet, "/api/preview/"+share.Token, nil), "").Code)
+	assert.Equal(t, http.StatusOK, s.download(share.Token).Code)
+}
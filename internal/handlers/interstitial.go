@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/digest"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+	"github.com/hardiksharma/shreadbox/internal/middleware"
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
+	"github.com/hardiksharma/shreadbox/internal/storage"
+)
+
+// ClientHeader must be sent by scripts fetching /api/download directly
+const ClientHeader = "X-ShreadBox-Client"
+
+const (
+	// nonceTTL is how long a confirmation page may be left open
+	nonceTTL = 10 * time.Minute
+
+	// csrfCookie binds a confirmation nonce to the browser it was issued to
+	csrfCookie = "sb_csrf"
+)
+
+var errInvalidNonce = errors.New("invalid or expired download nonce")
+
+// downloadPage is the data rendered by download.html
+type downloadPage struct {
+	Token         string
+	FileName      string
+	FileSize      string
+	Message       string
+	ExpiresAt     time.Time
+	DownloadsLeft int
+	Sealed        bool
+	PreviewsLeft  int    // 0 when the share has no preview
+	SHA256        string // hex, for checking the downloaded file
+	Nonce         string
+	Notice        string // shown instead of the download button
+	Error         string
+}
+
+// DownloadPage shows what a link shares without consuming a download, so
+// link previews and mail scanners can fetch it harmlessly. The download
+// itself needs a POST carrying the page's nonce.
+func (h *Handler) DownloadPage(c *gin.Context) {
+	token := c.Param("token")
+	c.Header("Cache-Control", "no-store")
+
+	// Guessing word codes here is throttled like the API
+	fileID, err := h.resolveToken(c, token)
+	if errors.Is(err, sharetoken.ErrTooManyAttempts) {
+		h.renderDownloadPage(c, http.StatusTooManyRequests, downloadPage{Error: "Too many unknown links were tried. Please wait a few minutes."})
+		return
+	}
+	// The page is rendered from a copy, as downloads may be counting the
+	// share down meanwhile
+	var metadata *storage.FileMetadata
+	if err == nil {
+		metadata, err = h.storage.GetFileMetadata(fileID)
+	}
+	now := time.Now()
+	if err != nil || !now.Before(metadata.ExpiresAt) || metadata.DownloadsLeft <= 0 ||
+		metadata.ScanStatus == storage.ScanInfected || metadata.ScanStatus == storage.ScanFailed {
+		// Every unavailable share looks the same, so pages do not reveal
+		// whether a token ever existed
+		h.renderDownloadPage(c, http.StatusNotFound, downloadPage{Error: "This link has expired or does not exist."})
+		return
+	}
+
+	page := downloadPage{
+		Token:         token,
+		FileName:      metadata.FileName,
+		FileSize:      formatSize(metadata.FileSize),
+		Message:       metadata.Message,
+		ExpiresAt:     metadata.ExpiresAt,
+		DownloadsLeft: metadata.DownloadsLeft,
+		Sealed:        len(metadata.WrappedKey) > 0,
+	}
+	if metadata.PreviewPath != "" {
+		page.PreviewsLeft = metadata.PreviewsLeft
+	}
+	var digests digest.Digests
+	if err := encryption.DecryptJSON(metadata.Digests, metadata.EncryptionKey, &digests); err == nil {
+		page.SHA256 = hex.EncodeToString(digests.SHA256)
+	}
+	if metadata.ScanStatus == storage.ScanPending {
+		page.Notice = "This file is still being checked for malware. Please try again in a minute."
+		h.renderDownloadPage(c, http.StatusOK, page)
+		return
+	}
+
+	csrf := make([]byte, 16)
+	if _, err := rand.Read(csrf); err != nil {
+		c.Error(fmt.Errorf("failed to generate CSRF token: %w", err))
+		return
+	}
+	csrfValue := base64.RawURLEncoding.EncodeToString(csrf)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    csrfValue,
+		Path:     "/d/",
+		MaxAge:   int(nonceTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	page.Nonce = h.signNonce(token, csrfValue, now.Add(nonceTTL))
+	h.renderDownloadPage(c, http.StatusOK, page)
+}
+
+// ConfirmDownload performs the download confirmed on the download page
+func (h *Handler) ConfirmDownload(c *gin.Context) {
+	token := c.Param("token")
+	c.Header("Cache-Control", "no-store")
+
+	// Stale or forged confirmations go back to a fresh page
+	csrf, err := c.Cookie(csrfCookie)
+	if err != nil || h.verifyNonce(c.PostForm("nonce"), token, csrf, time.Now()) != nil {
+		c.Redirect(http.StatusSeeOther, c.Request.URL.Path)
+		return
+	}
+
+	if err := h.serveDownload(c, token); err != nil {
+		problem := middleware.Conceal(c, middleware.NewProblem(err, true))
+		if problem.Status >= http.StatusInternalServerError {
+			log.Printf("Confirmed download failed: %v", err)
+		}
+		h.renderDownloadPage(c, problem.Status, downloadPage{Error: problem.Detail})
+	}
+}
+
+func (h *Handler) renderDownloadPage(c *gin.Context, status int, page downloadPage) {
+	c.HTML(status, "download.html", gin.H{
+		"title": "ShreadBox - Download",
+		"nav":   "download",
+		"page":  page,
+	})
+}
+
+// signNonce binds a token and the browser's CSRF cookie until expires:
+// base64url(expiry unix seconds | HMAC-SHA256)
+func (h *Handler) signNonce(token, csrf string, expires time.Time) string {
+	nonce := binary.BigEndian.AppendUint64(nil, uint64(expires.Unix()))
+	nonce = append(nonce, h.nonceMAC(token, csrf, nonce[:8])...)
+	return base64.RawURLEncoding.EncodeToString(nonce)
+}
+
+func (h *Handler) verifyNonce(nonce, token, csrf string, now time.Time) error {
+	raw, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(raw) != 8+sha256.Size {
+		return errInvalidNonce
+	}
+
+	expires := time.Unix(int64(binary.BigEndian.Uint64(raw[:8])), 0)
+	if !now.Before(expires) || !hmac.Equal(raw[8:], h.nonceMAC(token, csrf, raw[:8])) {
+		return errInvalidNonce
+	}
+	return nil
+}
+
+func (h *Handler) nonceMAC(token, csrf string, expires []byte) []byte {
+	mac := hmac.New(sha256.New, h.opts.LinkSecret)
+	mac.Write([]byte("download\x00" + token + "\x00" + csrf + "\x00"))
+	mac.Write(expires)
+	return mac.Sum(nil)
+}
+
+// formatSize renders a byte count for people
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/contenttype"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+	"github.com/hardiksharma/shreadbox/internal/middleware"
+	"github.com/hardiksharma/shreadbox/internal/quota"
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
+	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/hardiksharma/shreadbox/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// templates returns the embedded page templates
+func templates(t *testing.T) *template.Template {
+	t.Helper()
+	tmpl, err := web.Templates()
+	require.NoError(t, err)
+	return tmpl
+}
+
+func TestDownloadNonce(t *testing.T) {
+	h := NewHandler(nil, nil, Options{})
+	now := time.Now()
+	nonce := h.signNonce("token", "csrf", now.Add(nonceTTL))
+
+	assert.NoError(t, h.verifyNonce(nonce, "token", "csrf", now))
+	assert.ErrorIs(t, h.verifyNonce(nonce, "token", "csrf", now.Add(nonceTTL)), errInvalidNonce, "expired")
+	assert.ErrorIs(t, h.verifyNonce(nonce, "other", "csrf", now), errInvalidNonce, "bound to the token")
+	assert.ErrorIs(t, h.verifyNonce(nonce, "token", "stolen", now), errInvalidNonce, "bound to the cookie")
+	assert.ErrorIs(t, h.verifyNonce("garbage", "token", "csrf", now), errInvalidNonce)
+
+	// Nonces from another server secret are rejected
+	other := NewHandler(nil, nil, Options{})
+	assert.ErrorIs(t, other.verifyNonce(nonce, "token", "csrf", now), errInvalidNonce)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KB", formatSize(1536))
+	assert.Equal(t, "10.0 MB", formatSize(10<<20))
+}
+
+func TestTwoStepDownload(t *testing.T) {
+	store, err := storage.NewStorage(t.TempDir())
+	require.NoError(t, err)
+	h := NewHandler(store, quota.NewManager(quota.Limits{}), Options{
+		TypePolicy: contenttype.NewPolicy(nil, nil, nil, nil),
+	})
+
+	encrypted, key, err := encryption.EncryptFile([]byte("hello"))
+	require.NoError(t, err)
+	metadata := &storage.FileMetadata{
+		FileName:      "hello.txt",
+		EncryptionKey: key,
+		ExpiresAt:     time.Now().Add(time.Hour),
+		DownloadsLeft: 1,
+		ContentType:   "text/plain",
+		FileSize:      5,
+	}
+	require.NoError(t, store.SaveFile(encrypted, metadata))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler(false))
+	router.SetHTMLTemplate(templates(t))
+	router.GET("/api/download/:token", h.Download)
+	router.GET("/d/:token", h.DownloadPage)
+	router.POST("/d/:token", h.ConfirmDownload)
+
+	// Browsers and bots hitting the API are sent to the confirmation page
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/download/"+metadata.Token, nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/d/"+metadata.Token, w.Header().Get("Location"))
+
+	// The page can be fetched any number of times without consuming anything
+	var page *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		page = httptest.NewRecorder()
+		router.ServeHTTP(page, httptest.NewRequest(http.MethodGet, "/d/"+metadata.Token, nil))
+		assert.Equal(t, http.StatusOK, page.Code)
+		assert.Contains(t, page.Body.String(), "hello.txt")
+	}
+	assert.Equal(t, 1, metadata.DownloadsLeft)
+
+	nonce := regexp.MustCompile(`name="nonce" value="([^"]+)"`).FindStringSubmatch(page.Body.String())
+	require.Len(t, nonce, 2)
+	cookies := page.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	confirm := func(nonce string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/d/"+metadata.Token, strings.NewReader(url.Values{"nonce": {nonce}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Confirmations without the page's cookie or nonce start over
+	assert.Equal(t, http.StatusSeeOther, confirm(nonce[1], nil).Code)
+	assert.Equal(t, http.StatusSeeOther, confirm("forged", cookies[0]).Code)
+	assert.Equal(t, 1, metadata.DownloadsLeft)
+
+	w = confirm(nonce[1], cookies[0])
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+
+	// Used up links show an error page
+	w = confirm(nonce[1], cookies[0])
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "file not found")
+
+	// Scripts sending the client header download directly
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/download/"+metadata.Token, nil)
+	req.Header.Set(ClientHeader, "test")
+	router.ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusSeeOther, w.Code)
+}
+
+func TestTokenGuessingThrottled(t *testing.T) {
+	store, err := storage.NewStorage(t.TempDir())
+	require.NoError(t, err)
+	h := NewHandler(store, quota.NewManager(quota.Limits{}), Options{
+		Tokens: sharetoken.NewLimiter(2, time.Minute),
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler(false))
+	router.SetHTMLTemplate(templates(t))
+	router.GET("/api/status/:token", h.Status)
+	router.GET("/d/:token", h.DownloadPage)
+
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// Unknown tokens count against the client on every route
+	assert.Equal(t, http.StatusNotFound, get("/api/status/7-apple-river"))
+	assert.Equal(t, http.StatusNotFound, get("/d/8-apple-river"))
+	assert.Equal(t, http.StatusTooManyRequests, get("/api/status/9-apple-river"))
+	assert.Equal(t, http.StatusTooManyRequests, get("/d/9-apple-river"))
+}
+
+func TestDownloadPageDuringDownloads(t *testing.T) {
+	s := newTestServer(t, Options{})
+	share := s.upload(t, map[string]string{"downloads_allowed": "20"}, []byte("hello"), "")
+
+	// Pages are rendered from a copy of the share, not the metadata the
+	// downloads are counting down
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, s.download(share.Token).Code)
+		}()
+		go func() {
+			defer wg.Done()
+			w := s.serve(httptest.NewRequest(http.MethodGet, "/d/"+share.Token, nil), "")
+			assert.Contains(t, []int{http.StatusOK, http.StatusNotFound}, w.Code)
+		}()
+	}
+	wg.Wait()
+
+	w := s.serve(httptest.NewRequest(http.MethodGet, "/d/"+share.Token, nil), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// ManagementHeader carries the management token returned with an upload
+const ManagementHeader = "X-Management-Token"
+
+var errNoManagementToken = domain.NewError(domain.ErrUnauthorized, "the "+ManagementHeader+" header is required")
+
+// ManagedShare describes a share to whoever holds its management token,
+// which is how the web UI follows shares uploaded without an API key.
+// Holders see the attempts refused by the share's access rules, as the
+// owner's API key does through Status.
+func (h *Handler) ManagedShare(c *gin.Context) {
+	token := c.GetHeader(ManagementHeader)
+	if token == "" {
+		c.Error(errNoManagementToken)
+		return
+	}
+
+	metadata, err := h.storage.Manage(c.Param("id"), token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := shareSummary(*metadata)
+	response["download_count"] = metadata.DownloadCount
+	if metadata.PreviewPath != "" {
+		response["previews_left"] = metadata.PreviewsLeft
+	}
+	if metadata.Access != nil {
+		response["access_denials"] = metadata.AccessDenials
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeManagedShare destroys a share before it expires on behalf of
+// whoever holds its management token
+func (h *Handler) RevokeManagedShare(c *gin.Context) {
+	token := c.GetHeader(ManagementHeader)
+	if token == "" {
+		c.Error(errNoManagementToken)
+		return
+	}
+
+	if err := h.storage.RevokeManaged(c.Param("id"), token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+const (
+	// formOverhead is the room allowed for multipart boundaries, part
+	// headers and text fields on top of the file itself
+	formOverhead = 1 << 20
+	// maxFieldSize limits a single text field
+	maxFieldSize = 64 << 10
+)
+
+var errFileTooLarge = domain.NewError(domain.ErrTooLarge, "file exceeds the maximum allowed size")
+
+// uploadForm is a parsed multipart upload
+type uploadForm struct {
+	FileName string
+	Data     []byte
+	Fields   map[string]string
+}
+
+// readUploadForm streams a multipart upload without spooling it to temporary
+// files, rejecting it as soon as the file part grows beyond maxSize
+func readUploadForm(w http.ResponseWriter, r *http.Request, maxSize int64) (*uploadForm, error) {
+	// Reject early when the declared size is already too large
+	if r.ContentLength > maxSize+formOverhead {
+		return nil, errFileTooLarge
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+formOverhead)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, domain.NewError(domain.ErrInvalidInput, "expected a multipart/form-data body")
+	}
+
+	form := &uploadForm{Fields: make(map[string]string)}
+	hasFile := false
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, classifyReadError(err)
+		}
+
+		switch {
+		case part.FormName() == "file" && part.FileName() != "":
+			if hasFile {
+				part.Close()
+				return nil, domain.NewError(domain.ErrInvalidInput, "only one file may be uploaded")
+			}
+
+			// Read one byte past the limit to detect oversize files
+			data, err := io.ReadAll(io.LimitReader(part, maxSize+1))
+			if err != nil {
+				part.Close()
+				return nil, classifyReadError(err)
+			}
+			if int64(len(data)) > maxSize {
+				part.Close()
+				return nil, errFileTooLarge
+			}
+
+			form.FileName = part.FileName()
+			form.Data = data
+			hasFile = true
+
+		case part.FormName() != "":
+			value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+			if err != nil {
+				part.Close()
+				return nil, classifyReadError(err)
+			}
+			if len(value) > maxFieldSize {
+				part.Close()
+				return nil, domain.NewError(domain.ErrInvalidInput, fmt.Sprintf("field %q is too long", part.FormName()))
+			}
+			form.Fields[part.FormName()] = string(value)
+		}
+		part.Close()
+	}
+
+	if !hasFile {
+		return nil, domain.NewError(domain.ErrInvalidInput, "no file provided")
+	}
+	return form, nil
+}
+
+// classifyReadError distinguishes bodies cut off by MaxBytesReader from malformed ones
+func classifyReadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errFileTooLarge
+	}
+	return domain.NewError(domain.ErrInvalidInput, "malformed multipart body")
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMultipartRequest(t *testing.T, fields map[string]string, fileName string, fileData []byte) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	if fileName != "" {
+		part, err := writer.CreateFormFile("file", fileName)
+		require.NoError(t, err)
+		_, err = part.Write(fileData)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestReadUploadForm(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100<<10)
+	req := newMultipartRequest(t, map[string]string{"message": "hello", "downloads_allowed": "3"}, "report.txt", data)
+
+	form, err := readUploadForm(httptest.NewRecorder(), req, 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, "report.txt", form.FileName)
+	assert.Equal(t, data, form.Data)
+	assert.Equal(t, "hello", form.Fields["message"])
+	assert.Equal(t, "3", form.Fields["downloads_allowed"])
+}
+
+func TestReadUploadForm_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  func() *http.Request
+		max  int64
+		kind error
+	}{
+		{
+			name: "file over limit",
+			req: func() *http.Request {
+				return newMultipartRequest(t, nil, "big.bin", make([]byte, 2048))
+			},
+			max:  1024,
+			kind: domain.ErrTooLarge,
+		},
+		{
+			name: "declared length over limit",
+			req: func() *http.Request {
+				req := newMultipartRequest(t, nil, "small.bin", []byte("x"))
+				req.ContentLength = 10 << 20
+				return req
+			},
+			max:  1024,
+			kind: domain.ErrTooLarge,
+		},
+		{
+			name: "no file",
+			req: func() *http.Request {
+				return newMultipartRequest(t, map[string]string{"message": "hi"}, "", nil)
+			},
+			max:  1024,
+			kind: domain.ErrInvalidInput,
+		},
+		{
+			name: "not multipart",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/api/upload", strings.NewReader("{}"))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			max:  1024,
+			kind: domain.ErrInvalidInput,
+		},
+		{
+			name: "oversize field",
+			req: func() *http.Request {
+				return newMultipartRequest(t, map[string]string{"message": strings.Repeat("m", maxFieldSize+1)}, "a.txt", []byte("x"))
+			},
+			max:  1024,
+			kind: domain.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readUploadForm(httptest.NewRecorder(), tt.req(), tt.max)
+			assert.ErrorIs(t, err, tt.kind)
+		})
+	}
+}
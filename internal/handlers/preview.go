@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+)
+
+// Preview sends a share's thumbnail or first page without consuming a
+// download. Each view counts against the share's previews instead.
+func (h *Handler) Preview(c *gin.Context) {
+	fileID, err := h.resolveToken(c, c.Param("token"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Previews reveal content, so the share's access rules apply
+	clientIP, err := netip.ParseAddr(c.ClientIP())
+	if err != nil {
+		c.Error(fmt.Errorf("failed to parse client address: %w", err))
+		return
+	}
+	if err := h.storage.AuthorizeAccess(fileID, clientIP); err != nil {
+		c.Error(err)
+		return
+	}
+
+	metadata, encrypted, err := h.storage.GetPreview(fileID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	content, err := encryption.Decrypt(encrypted, metadata.EncryptionKey)
+	if err != nil {
+		c.Error(fmt.Errorf("failed to decrypt preview: %w", err))
+		return
+	}
+
+	// Shown inline but sandboxed, and never cached
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, metadata.PreviewType, content)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/qrcode"
+)
+
+// QRCode renders a QR code of a share's link for scanning with a phone.
+// Rendering the code does not count as a download.
+func (h *Handler) QRCode(c *gin.Context) {
+	token := c.Param("token")
+	if _, err := h.resolveToken(c, token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	format := c.DefaultQuery("format", qrcode.FormatPNG)
+	size := qrcode.DefaultSize
+	if value := c.Query("size"); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil {
+			c.Error(qrcode.ErrInvalidSize)
+			return
+		}
+	}
+
+	image, err := qrcode.Render(h.opts.BaseURL+"/d/"+url.PathEscape(token), format, size)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// The code is as much a secret as the link it holds
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, qrcode.ContentType(format), image)
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"filippo.io/age"
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/hardiksharma/shreadbox/internal/contenttype"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+	"github.com/hardiksharma/shreadbox/internal/middleware"
+	"github.com/hardiksharma/shreadbox/internal/storage"
+)
+
+// createRequestBody is the JSON body for creating a file request
+type createRequestBody struct {
+	Title            string `json:"title"`
+	Message          string `json:"message"`
+	RecipientKeys    string `json:"recipient_keys"`
+	ExpiryTime       string `json:"expiry_time"`
+	MaxUploads       int    `json:"max_uploads"`
+	FileExpiryTime   string `json:"file_expiry_time"`
+	DownloadsAllowed int    `json:"downloads_allowed"`
+}
+
+// CreateRequest creates an inbound link outsiders can upload files to.
+// Without recipient keys a key pair is generated and its private half is
+// returned once, so only the requester can open the received files.
+func (h *Handler) CreateRequest(c *gin.Context) {
+	var body createRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(domain.NewError(domain.ErrInvalidInput, "invalid request body"))
+		return
+	}
+	if body.Title == "" {
+		c.Error(domain.NewError(domain.ErrInvalidInput, "title is required"))
+		return
+	}
+
+	var policy auth.Policy
+	if key := middleware.APIKey(c); key != nil {
+		policy = key.Policy
+	}
+
+	// The request's own lifetime defaults to a week, received files to a day
+	expiry := parseExpiry(body.ExpiryTime, 7*24*time.Hour, policy.MaxExpiry)
+	fileExpiry := parseExpiry(body.FileExpiryTime, 24*time.Hour, policy.MaxExpiry)
+	downloads := body.DownloadsAllowed
+	if downloads < 1 {
+		downloads = 1
+	}
+	if body.MaxUploads < 0 {
+		c.Error(domain.NewError(domain.ErrInvalidInput, "max_uploads must not be negative"))
+		return
+	}
+
+	if policy.MaxExpiry > 0 && (expiry > policy.MaxExpiry || fileExpiry > policy.MaxExpiry) {
+		c.Error(domain.NewError(domain.ErrInvalidInput, "expiry exceeds the maximum allowed for this key"))
+		return
+	}
+	if policy.MaxDownloads > 0 && downloads > policy.MaxDownloads {
+		c.Error(domain.NewError(domain.ErrInvalidInput, "downloads exceed the maximum allowed for this key"))
+		return
+	}
+
+	recipientKeys := body.RecipientKeys
+	if _, err := parseRecipientKeys(recipientKeys); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var identity *age.X25519Identity
+	if recipientKeys == "" {
+		var err error
+		if identity, err = age.GenerateX25519Identity(); err != nil {
+			c.Error(err)
+			return
+		}
+		recipientKeys = identity.Recipient().String()
+	}
+
+	request := &storage.FileRequest{
+		Title:          body.Title,
+		Message:        body.Message,
+		Owner:          clientIdentity(c),
+		RecipientKeys:  recipientKeys,
+		MaxFileSize:    policy.MaxFileSize,
+		MaxUploads:     body.MaxUploads,
+		ShareExpiry:    fileExpiry,
+		ShareDownloads: downloads,
+		ExpiresAt:      time.Now().Add(expiry),
+	}
+	h.storage.CreateRequest(request)
+
+	response := gin.H{
+		"id":         request.ID,
+		"title":      request.Title,
+		"upload_url": "/api/requests/" + request.ID + "/upload",
+		"expires_at": request.ExpiresAt,
+	}
+	if identity != nil {
+		response["identity"] = identity.String()
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetRequest describes an open file request to the people uploading to it
+func (h *Handler) GetRequest(c *gin.Context) {
+	request, err := h.storage.GetRequest(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !request.Open(time.Now()) {
+		c.Error(storage.ErrRequestFull)
+		return
+	}
+
+	maxSize := h.opts.MaxFileSize
+	if request.MaxFileSize > 0 {
+		maxSize = request.MaxFileSize
+	}
+
+	response := gin.H{
+		"title":         request.Title,
+		"message":       request.Message,
+		"expires_at":    request.ExpiresAt,
+		"max_file_size": maxSize,
+	}
+	if request.MaxUploads > 0 {
+		response["uploads_left"] = request.MaxUploads - request.Uploads
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UploadToRequest accepts a file for a request without authentication. The
+// file is sealed to the requester and charged to their quota.
+func (h *Handler) UploadToRequest(c *gin.Context) {
+	request, err := h.storage.ReserveRequestUpload(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.receiveRequestUpload(c, request); err != nil {
+		h.storage.CancelRequestUpload(request.ID)
+		c.Error(err)
+		return
+	}
+}
+
+func (h *Handler) receiveRequestUpload(c *gin.Context, request storage.FileRequest) error {
+	maxSize := h.opts.MaxFileSize
+	if request.MaxFileSize > 0 {
+		maxSize = request.MaxFileSize
+	}
+
+	form, err := readUploadForm(c.Writer, c.Request, maxSize)
+	if err != nil {
+		return err
+	}
+
+	contentType := contenttype.Detect(form.FileName, form.Data)
+	if err := h.opts.TypePolicy.Check(form.FileName, contentType); err != nil {
+		return err
+	}
+
+	recipientKeys, err := parseRecipientKeys(request.RecipientKeys)
+	if err != nil {
+		return err
+	}
+
+	digests, err := h.digestUpload(form.Data, form.Fields["expected_digest"])
+	if err != nil {
+		return err
+	}
+
+	metadata := &storage.FileMetadata{
+		FileName:      form.FileName,
+		ExpiresAt:     time.Now().Add(request.ShareExpiry),
+		DownloadsLeft: request.ShareDownloads,
+		Message:       form.Fields["message"],
+		ContentType:   contentType,
+		Owner:         request.Owner,
+		RequestID:     request.ID,
+	}
+	if err := h.saveShare(form.Data, metadata, shareOptions{recipientKeys: recipientKeys, digests: digests}); err != nil {
+		return err
+	}
+
+	// The uploader gets no token, only the requester can fetch the file
+	c.JSON(http.StatusCreated, gin.H{
+		"file_name": metadata.FileName,
+		"file_size": metadata.FileSize,
+		"digests":   digests.Hex(),
+	})
+	return nil
+}
+
+// MyRequests lists the file requests created with the caller's API key
+func (h *Handler) MyRequests(c *gin.Context) {
+	requests := h.storage.ListRequests(clientIdentity(c))
+	if requests == nil {
+		requests = []storage.FileRequest{}
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// RequestFiles lists the files received by one of the caller's requests
+func (h *Handler) RequestFiles(c *gin.Context) {
+	files, err := h.storage.ListRequestFiles(c.Param("id"), clientIdentity(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := make([]gin.H, 0, len(files))
+	for _, metadata := range files {
+		summary := shareSummary(metadata)
+		summary["download_url"] = "/api/me/requests/" + metadata.RequestID + "/files/" + metadata.ID
+		response = append(response, summary)
+	}
+	c.JSON(http.StatusOK, gin.H{"files": response})
+}
+
+// DownloadRequestFile sends a file received by one of the caller's
+// requests. Uploaders get no token, so the requester fetches files by ID
+// with the key that created the request; this counts a download like any
+// other.
+func (h *Handler) DownloadRequestFile(c *gin.Context) {
+	metadata, err := h.storage.ReceivedFile(c.Param("id"), c.Param("file"), clientIdentity(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.sendShare(c, metadata.ID); err != nil {
+		c.Error(err)
+	}
+}
+
+// CloseRequest stops a request accepting uploads, received files are kept
+// until they expire
+func (h *Handler) CloseRequest(c *gin.Context) {
+	if err := h.storage.CloseRequest(c.Param("id"), clientIdentity(c)); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createdRequest is the response to creating a file request
+type createdRequest struct {
+	ID        string `json:"id"`
+	UploadURL string `json:"upload_url"`
+	Identity  string `json:"identity"`
+}
+
+// receivedFile is a file as listed for a request
+type receivedFile struct {
+	ID            string `json:"id"`
+	FileName      string `json:"file_name"`
+	DownloadsLeft int    `json:"downloads_left"`
+	DownloadURL   string `json:"download_url"`
+}
+
+// createRequest creates a file request with the API key secret
+func (s *testServer) createRequest(t *testing.T, body string, secret string) createdRequest {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/requests", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := s.serve(req, secret)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created createdRequest
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	return created
+}
+
+// uploadToRequest posts a file to a request without authentication
+func (s *testServer) uploadToRequest(t *testing.T, uploadURL, fileName string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := newMultipartRequest(t, nil, fileName, data)
+	req.URL.Path = uploadURL
+	return s.serve(req, "")
+}
+
+// requestFiles lists the files received by a request
+func (s *testServer) requestFiles(t *testing.T, id, secret string) []receivedFile {
+	t.Helper()
+	w := s.serve(httptest.NewRequest(http.MethodGet, "/api/me/requests/"+id+"/files", nil), secret)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var listing struct {
+		Files []receivedFile `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	return listing.Files
+}
+
+func TestDownloadRequestFile(t *testing.T) {
+	s := newTestServer(t, Options{})
+	secret := s.apiKey(t, auth.Policy{})
+	other := s.apiKey(t, auth.Policy{})
+
+	request := s.createRequest(t, `{"title": "Send us your logs", "downloads_allowed": 2}`, secret)
+	identity, err := age.ParseX25519Identity(request.Identity)
+	require.NoError(t, err)
+
+	content := []byte("2024-01-01 something broke\n")
+	w := s.uploadToRequest(t, request.UploadURL, "app.log", content)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "token")
+
+	files := s.requestFiles(t, request.ID, secret)
+	require.Len(t, files, 1)
+	assert.Equal(t, "app.log", files[0].FileName)
+	assert.Equal(t, 2, files[0].DownloadsLeft)
+	assert.Equal(t, "/api/me/requests/"+request.ID+"/files/"+files[0].ID, files[0].DownloadURL)
+
+	// Only the key that created the request can fetch its files
+	get := func(path, secret string) *httptest.ResponseRecorder {
+		return s.serve(httptest.NewRequest(http.MethodGet, path, nil), secret)
+	}
+	assert.Equal(t, http.StatusUnauthorized, get(files[0].DownloadURL, "").Code)
+	assert.Equal(t, http.StatusNotFound, get(files[0].DownloadURL, other).Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/me/requests/"+request.ID+"/files/"+request.ID, secret).Code)
+
+	// Shares uploaded directly are not reachable through a request
+	share := s.upload(t, nil, []byte("hello"), secret)
+	assert.Equal(t, http.StatusNotFound, get("/api/me/requests/"+request.ID+"/files/"+share.ID, secret).Code)
+
+	// The requester gets an envelope they open with the request's identity,
+	// each download counting against the file
+	for range 2 {
+		w = get(files[0].DownloadURL, secret)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, encryption.EnvelopeContentType, w.Header().Get("Content-Type"))
+		opened, err := encryption.OpenEnvelope(w.Body.Bytes(), identity)
+		require.NoError(t, err)
+		assert.Equal(t, content, opened)
+	}
+	assert.Equal(t, http.StatusNotFound, get(files[0].DownloadURL, secret).Code)
+	assert.Empty(t, s.requestFiles(t, request.ID, secret))
+}
+
+func TestFileRequestLifecycle(t *testing.T) {
+	s := newTestServer(t, Options{})
+	secret := s.apiKey(t, auth.Policy{})
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	// Requests sealed to the requester's own key return no identity
+	request := s.createRequest(t, `{"title": "Send us your logs", "max_uploads": 2, "recipient_keys": "`+identity.Recipient().String()+`"}`, secret)
+	assert.Equal(t, "/api/requests/"+request.ID+"/upload", request.UploadURL)
+	assert.Empty(t, request.Identity)
+
+	// Anyone with the link sees what is asked for
+	w := s.serve(httptest.NewRequest(http.MethodGet, "/api/requests/"+request.ID, nil), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var described struct {
+		Title       string `json:"title"`
+		UploadsLeft int    `json:"uploads_left"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &described))
+	assert.Equal(t, "Send us your logs", described.Title)
+	assert.Equal(t, 2, described.UploadsLeft)
+
+	// Uploads are accepted until the request is full
+	uploads := map[string][]byte{"first.log": []byte("first"), "second.log": []byte("second")}
+	for name, content := range uploads {
+		w = s.uploadToRequest(t, request.UploadURL, name, content)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	assert.Equal(t, http.StatusGone, s.uploadToRequest(t, request.UploadURL, "third.log", []byte("third")).Code)
+	assert.Equal(t, http.StatusGone, s.serve(httptest.NewRequest(http.MethodGet, "/api/requests/"+request.ID, nil), "").Code)
+
+	// The requester sees the request and what it received, charged to them
+	w = s.serve(httptest.NewRequest(http.MethodGet, "/api/me/requests", nil), secret)
+	require.Equal(t, http.StatusOK, w.Code)
+	var mine struct {
+		Requests []struct {
+			ID      string `json:"id"`
+			Uploads int    `json:"uploads"`
+		} `json:"requests"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &mine))
+	require.Len(t, mine.Requests, 1)
+	assert.Equal(t, request.ID, mine.Requests[0].ID)
+	assert.Equal(t, 2, mine.Requests[0].Uploads)
+	key, err := s.keys.Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.quotas.Usage("key:"+key.ID).Shares)
+
+	files := s.requestFiles(t, request.ID, secret)
+	require.Len(t, files, 2)
+
+	// Other keys cannot see or close the request
+	other := s.apiKey(t, auth.Policy{})
+	assert.Equal(t, http.StatusNotFound, s.serve(httptest.NewRequest(http.MethodGet, "/api/me/requests/"+request.ID+"/files", nil), other).Code)
+	assert.Equal(t, http.StatusNotFound, s.serve(httptest.NewRequest(http.MethodDelete, "/api/me/requests/"+request.ID, nil), other).Code)
+
+	// Closing stops the link working but keeps the received files listed
+	require.Equal(t, http.StatusNoContent, s.serve(httptest.NewRequest(http.MethodDelete, "/api/me/requests/"+request.ID, nil), secret).Code)
+	assert.Equal(t, http.StatusNotFound, s.serve(httptest.NewRequest(http.MethodGet, "/api/requests/"+request.ID, nil), "").Code)
+	assert.Equal(t, http.StatusNotFound, s.uploadToRequest(t, request.UploadURL, "late.log", []byte("late")).Code)
+	assert.ElementsMatch(t, files, s.requestFiles(t, request.ID, secret))
+	assert.Equal(t, http.StatusNotFound, s.serve(httptest.NewRequest(http.MethodGet, "/api/me/requests/"+request.ID+"/files", nil), other).Code)
+
+	for _, file := range files {
+		w = s.serve(httptest.NewRequest(http.MethodGet, file.DownloadURL, nil), secret)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		opened, err := encryption.OpenEnvelope(w.Body.Bytes(), identity)
+		require.NoError(t, err)
+		assert.Equal(t, uploads[file.FileName], opened)
+	}
+	assert.Zero(t, s.storage.Stats().ActiveShares)
+	assert.Zero(t, s.quotas.Total())
+
+	// Once the last received file is gone, so is the closed request
+	assert.Equal(t, http.StatusNotFound, s.serve(httptest.NewRequest(http.MethodGet, "/api/me/requests/"+request.ID+"/files", nil), secret).Code)
+}
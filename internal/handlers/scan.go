@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/storage"
+)
+
+// scanAttempts is how often a scan is tried before the file is given up on
+const scanAttempts = 3
+
+// scanFile scans an uploaded file and records the verdict. Files that cannot
+// be scanned are treated like infected ones, so nothing unscanned is relayed.
+// token is only used to email the link once the file is clean.
+func (h *Handler) scanFile(id, token string, data []byte) {
+	status, signature := storage.ScanFailed, ""
+
+	for attempt := 1; attempt <= scanAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), h.opts.ScanTimeout)
+		result, err := h.opts.Scanner.Scan(ctx, bytes.NewReader(data))
+		cancel()
+
+		if err == nil {
+			status, signature = storage.ScanClean, ""
+			if result.Infected {
+				status, signature = storage.ScanInfected, result.Signature
+			}
+			break
+		}
+
+		log.Printf("Scan attempt %d for %s failed: %v", attempt, id, err)
+		if attempt < scanAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+
+	if status == storage.ScanInfected {
+		log.Printf("Malware detected in %s (%s), shredding", id, signature)
+	}
+	if err := h.storage.SetScanResult(id, status, signature); err != nil {
+		log.Printf("Failed to record scan result for %s: %v", id, err)
+		return
+	}
+
+	if status == storage.ScanClean && h.opts.Mail != nil {
+		// The copy is taken under the storage lock, downloads may start as
+		// soon as the verdict is recorded
+		if share, err := h.storage.GetFileMetadata(id); err == nil {
+			share.Token = token
+			h.opts.Mail.ShareReady(*share)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// maxRecipients caps how many addresses a single upload may email
+const maxRecipients = 10
+
+var (
+	ErrInvalidAddress  = domain.NewError(domain.ErrInvalidInput, "invalid email address")
+	ErrTooManyAddrs    = domain.NewError(domain.ErrInvalidInput, fmt.Sprintf("at most %d recipients are allowed", maxRecipients))
+	ErrMailUnavailable = domain.NewError(domain.ErrInvalidInput, "email notifications are not enabled on this server")
+)
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Options configures a Mailer
+type Options struct {
+	Host           string
+	Port           string
+	Username       string // optional, enables SMTP AUTH PLAIN
+	Password       string
+	From           string
+	QueueSize      int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+}
+
+// Mailer sends queued messages over SMTP in the background, so request
+// handlers never wait on the mail server
+type Mailer struct {
+	opts  Options
+	queue chan Message
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// NewMailer creates a new mailer, call Start to begin sending
+func NewMailer(opts Options) *Mailer {
+	if opts.Port == "" {
+		opts.Port = "587"
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 256
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 5 * time.Second
+	}
+
+	return &Mailer{
+		opts:  opts,
+		queue: make(chan Message, opts.QueueSize),
+		stop:  make(chan struct{}),
+	}
+}
+
+// Start launches the sending worker
+func (m *Mailer) Start() {
+	m.wg.Add(1)
+	go m.worker()
+}
+
+// Stop stops the worker, waiting for the message being sent until ctx is
+// done. Messages still queued are dropped.
+func (m *Mailer) Stop(ctx context.Context) {
+	m.once.Do(func() {
+		close(m.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	if dropped := len(m.queue); dropped > 0 {
+		log.Printf("Dropped %d queued emails on shutdown", dropped)
+	}
+}
+
+// Enqueue queues a message without blocking
+func (m *Mailer) Enqueue(msg Message) {
+	select {
+	case m.queue <- msg:
+	default:
+		log.Printf("Mail queue full, dropping %q to %s", msg.Subject, msg.To)
+	}
+}
+
+func (m *Mailer) worker() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case msg := <-m.queue:
+			m.process(msg)
+		}
+	}
+}
+
+// process sends a message, retrying with exponential backoff
+func (m *Mailer) process(msg Message) {
+	backoff := m.opts.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := m.send(msg)
+		if err == nil {
+			return
+		}
+		if attempt >= m.opts.MaxAttempts {
+			log.Printf("Failed to send %q to %s after %d attempts: %v", msg.Subject, msg.To, attempt, err)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *Mailer) send(msg Message) error {
+	var auth smtp.Auth
+	if m.opts.Username != "" {
+		auth = smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)
+	}
+
+	from, err := mail.ParseAddress(m.opts.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	data, err := m.render(from, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.opts.Host, m.opts.Port)
+	return smtp.SendMail(addr, auth, from.Address, []string{msg.To}, data)
+}
+
+// render builds the RFC 5322 message. Header values are encoded, so user
+// supplied text such as file names cannot inject headers.
+func (m *Mailer) render(from *mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", (&mail.Address{Address: msg.To}).String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", messageID(), domainOf(from.Address))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseAddresses parses a comma separated list of email addresses
+func ParseAddresses(list string) ([]string, error) {
+	var addresses []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(item)
+		if err != nil {
+			return nil, ErrInvalidAddress
+		}
+		addresses = append(addresses, addr.Address)
+	}
+	if len(addresses) > maxRecipients {
+		return nil, ErrTooManyAddrs
+	}
+	return addresses, nil
+}
+
+func messageID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func domainOf(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return address[at+1:]
+	}
+	return "localhost"
+}
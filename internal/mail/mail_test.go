@@ -0,0 +1,267 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// received is a message accepted by the fake SMTP server
+type received struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP is an in-process stand-in for an SMTP server, speaking just
+// enough of the protocol for net/smtp
+type fakeSMTP struct {
+	listener net.Listener
+	messages chan received
+	mu       sync.Mutex
+	failures int // transactions to reject before accepting mail
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &fakeSMTP{listener: listener, messages: make(chan received, 16)}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeSMTP) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var msg received
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case "MAIL":
+			msg = received{From: line}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			msg.Data = data.String()
+
+			s.mu.Lock()
+			reject := s.failures > 0
+			if reject {
+				s.failures--
+			}
+			s.mu.Unlock()
+			if reject {
+				reply("451 Try again later")
+				continue
+			}
+			s.messages <- msg
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTP) next(t *testing.T) received {
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return received{}
+	}
+}
+
+func newTestMailer(t *testing.T, server *fakeSMTP) *Mailer {
+	m := NewMailer(Options{
+		Host:           "127.0.0.1",
+		Port:           server.port(),
+		From:           "ShreadBox <noreply@example.com>",
+		InitialBackoff: time.Millisecond,
+	})
+	m.Start()
+	t.Cleanup(func() { m.Stop(context.Background()) })
+	return m
+}
+
+// parse decodes a received message into headers and body
+func parse(t *testing.T, msg received) (*mail.Message, string) {
+	parsed, err := mail.ReadMessage(strings.NewReader(msg.Data))
+	require.NoError(t, err)
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.NoError(t, err)
+	return parsed, string(body)
+}
+
+func TestMailer_Send(t *testing.T) {
+	server := newFakeSMTP(t)
+	m := newTestMailer(t, server)
+
+	m.Enqueue(Message{To: "alice@example.com", Subject: "Hello\r\nBcc: mallory@example.com", Body: "Line one\nLine two"})
+
+	msg := server.next(t)
+	assert.Contains(t, msg.From, "<noreply@example.com>")
+	assert.Equal(t, []string{"RCPT TO:<alice@example.com>"}, msg.To)
+
+	parsed, body := parse(t, msg)
+	assert.Equal(t, "<alice@example.com>", parsed.Header.Get("To"))
+	assert.Empty(t, parsed.Header.Get("Bcc"), "subjects must not inject headers")
+	assert.Equal(t, "Line one\r\nLine two", strings.TrimSpace(body))
+}
+
+func TestMailer_Retries(t *testing.T) {
+	server := newFakeSMTP(t)
+	server.failures = 2
+	m := newTestMailer(t, server)
+
+	m.Enqueue(Message{To: "alice@example.com", Subject: "Retry", Body: "body"})
+
+	msg := server.next(t)
+	parsed, _ := parse(t, msg)
+	assert.Equal(t, "Retry", parsed.Header.Get("Subject"))
+}
+
+func TestNotifier_ShareReady(t *testing.T) {
+	server := newFakeSMTP(t)
+	notifier := NewNotifier(newTestMailer(t, server), "https://files.example.com/")
+
+	notifier.ShareReady(storage.FileMetadata{
+		Token:         "abc123",
+		FileName:      "report.pdf",
+		Message:       "Q3 numbers",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		DownloadsLeft: 2,
+		Recipients:    []string{"alice@example.com", "bob@example.com"},
+	})
+
+	// Each recipient gets their own message
+	var recipients []string
+	for i := 0; i < 2; i++ {
+		msg := server.next(t)
+		require.Len(t, msg.To, 1)
+		recipients = append(recipients, msg.To[0])
+
+		parsed, body := parse(t, msg)
+		assert.Equal(t, "A file has been shared with you: report.pdf", parsed.Header.Get("Subject"))
+		assert.Contains(t, body, "https://files.example.com/d/abc123")
+		assert.Contains(t, body, "Q3 numbers")
+		assert.Contains(t, body, "2 downloads")
+	}
+	assert.ElementsMatch(t, []string{"RCPT TO:<alice@example.com>", "RCPT TO:<bob@example.com>"}, recipients)
+}
+
+func TestNotifier_Listener(t *testing.T) {
+	server := newFakeSMTP(t)
+	listener := NewNotifier(newTestMailer(t, server), "https://files.example.com").Listener()
+
+	file := storage.FileMetadata{
+		Token:         "abc123",
+		FileName:      "report.pdf",
+		UploaderEmail: "owner@example.com",
+		DownloadCount: 1,
+		DownloadsLeft: 1,
+	}
+
+	// Only the first download is reported
+	listener(storage.Event{Type: storage.EventDownloaded, File: file, OccurredAt: time.Now()})
+	second := file
+	second.DownloadCount = 2
+	listener(storage.Event{Type: storage.EventDownloaded, File: second, OccurredAt: time.Now()})
+	listener(storage.Event{Type: storage.EventExpired, File: second, OccurredAt: time.Now()})
+
+	parsed, _ := parse(t, server.next(t))
+	assert.Equal(t, "Your file was downloaded: report.pdf", parsed.Header.Get("Subject"))
+
+	parsed, body := parse(t, server.next(t))
+	assert.Equal(t, "Your share expired: report.pdf", parsed.Header.Get("Subject"))
+	assert.Contains(t, body, "after 2 downloads")
+
+	select {
+	case msg := <-server.messages:
+		t.Fatalf("unexpected message: %s", msg.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Shares without an uploader address are silent
+	anonymous := file
+	anonymous.UploaderEmail = ""
+	listener(storage.Event{Type: storage.EventDownloaded, File: anonymous, OccurredAt: time.Now()})
+	select {
+	case <-server.messages:
+		t.Fatal("anonymous share should not send mail")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestParseAddresses(t *testing.T) {
+	addresses, err := ParseAddresses(" alice@example.com, Bob <bob@example.com> ,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, addresses)
+
+	addresses, err = ParseAddresses("")
+	require.NoError(t, err)
+	assert.Empty(t, addresses)
+
+	_, err = ParseAddresses("not-an-address")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+
+	_, err = ParseAddresses(strings.Repeat("a@example.com,", maxRecipients+1))
+	assert.ErrorIs(t, err, ErrTooManyAddrs)
+}
@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/storage"
+)
+
+// Notifier emails share links to recipients and keeps uploaders informed
+// about what happened to their shares
+type Notifier struct {
+	mailer  *Mailer
+	baseURL string
+}
+
+// NewNotifier creates a notifier building links from baseURL
+func NewNotifier(mailer *Mailer, baseURL string) *Notifier {
+	return &Notifier{
+		mailer:  mailer,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// shareData is what templates can refer to
+type shareData struct {
+	storage.FileMetadata
+	Link       string
+	OccurredAt time.Time
+}
+
+// ShareReady emails the download link to the share's recipients. Each
+// recipient gets a separate message, so they never see each other.
+// metadata must carry the plaintext token, which storage does not keep.
+func (n *Notifier) ShareReady(metadata storage.FileMetadata) {
+	if len(metadata.Recipients) == 0 {
+		return
+	}
+
+	msg, err := renderTemplate("share.tmpl", shareData{
+		FileMetadata: metadata,
+		Link:         n.baseURL + "/d/" + metadata.Token,
+	})
+	if err != nil {
+		log.Printf("Failed to render share email for %s: %v", metadata.ID, err)
+		return
+	}
+
+	for _, recipient := range metadata.Recipients {
+		msg.To = recipient
+		n.mailer.Enqueue(msg)
+	}
+}
+
+// Listener returns a storage listener telling uploaders about the first
+// download and about expiry
+func (n *Notifier) Listener() storage.Listener {
+	return func(event storage.Event) {
+		if event.File.UploaderEmail == "" {
+			return
+		}
+
+		var name string
+		switch {
+		case event.Type == storage.EventDownloaded && event.File.DownloadCount == 1:
+			name = "downloaded.tmpl"
+		case event.Type == storage.EventExpired:
+			name = "expired.tmpl"
+		default:
+			return
+		}
+
+		msg, err := renderTemplate(name, shareData{
+			FileMetadata: event.File,
+			OccurredAt:   event.OccurredAt,
+		})
+		if err != nil {
+			log.Printf("Failed to render %s for %s: %v", name, event.File.ID, err)
+			return
+		}
+		msg.To = event.File.UploaderEmail
+		n.mailer.Enqueue(msg)
+	}
+}
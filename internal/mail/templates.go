@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// templates are plain text, the first line holds the subject
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+// errNoSubject is returned for templates missing their subject line
+var errNoSubject = errors.New("template does not start with a Subject line")
+
+// renderTemplate executes a template and splits it into subject and body
+func renderTemplate(name string, data any) (Message, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return Message{}, err
+	}
+
+	header, body, _ := strings.Cut(buf.String(), "\n")
+	subject, ok := strings.CutPrefix(header, "Subject: ")
+	if !ok {
+		return Message{}, errNoSubject
+	}
+	return Message{
+		Subject: strings.TrimSpace(subject),
+		Body:    strings.TrimLeft(body, "\n"),
+	}, nil
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// apiKeyContextKey is the gin context key holding the authenticated *auth.Key
+const apiKeyContextKey = "api_key"
+
+// Authenticate resolves the API key sent as "Authorization: Bearer <key>" or
+// "X-API-Key". Invalid keys are always rejected; missing keys are rejected
+// only when required is set.
+func Authenticate(keys *auth.KeyStore, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader("X-API-Key")
+		if bearer, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+			secret = strings.TrimSpace(bearer)
+		}
+
+		if secret == "" {
+			if required {
+				c.Header("WWW-Authenticate", "Bearer")
+				abortWithError(c, domain.NewError(domain.ErrUnauthorized, "API key required"))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		key, err := keys.Authenticate(secret)
+		if err != nil {
+			c.Header("WWW-Authenticate", "Bearer")
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// APIKey returns the key authenticated for this request, or nil
+func APIKey(c *gin.Context) *auth.Key {
+	if value, exists := c.Get(apiKeyContextKey); exists {
+		return value.(*auth.Key)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticate(t *testing.T) {
+	keys, err := auth.NewKeyStore(filepath.Join(t.TempDir(), "apikeys.json"))
+	require.NoError(t, err)
+	secret, key, err := keys.Create("test", auth.Policy{})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(false))
+	whoami := func(c *gin.Context) {
+		if key := APIKey(c); key != nil {
+			c.String(http.StatusOK, key.ID)
+			return
+		}
+		c.String(http.StatusOK, "anonymous")
+	}
+	router.GET("/optional", Authenticate(keys, false), whoami)
+	router.GET("/required", Authenticate(keys, true), whoami)
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		value    string
+		code     int
+		expected string
+	}{
+		{"optional anonymous", "/optional", "", "", http.StatusOK, "anonymous"},
+		{"optional bearer", "/optional", "Authorization", "Bearer " + secret, http.StatusOK, key.ID},
+		{"optional invalid", "/optional", "X-API-Key", "sbk_wrong", http.StatusUnauthorized, ""},
+		{"required anonymous", "/required", "", "", http.StatusUnauthorized, ""},
+		{"required header", "/required", "X-API-Key", secret, http.StatusOK, key.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, rec.Body.String())
+			}
+		})
+	}
+}
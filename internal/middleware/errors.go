@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// problemContentType is the RFC 7807 media type for error responses
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code is a stable,
+// machine-readable identifier clients can switch on.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+// errorMapping associates an error kind with its status and code
+type errorMapping struct {
+	kind   error
+	status int
+	code   string
+}
+
+var errorMappings = []errorMapping{
+	{domain.ErrInvalidInput, http.StatusBadRequest, "invalid_request"},
+	{domain.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{domain.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{domain.ErrNotFound, http.StatusNotFound, "not_found"},
+	{domain.ErrExpired, http.StatusGone, "expired"},
+	{domain.ErrExhausted, http.StatusGone, "download_limit_reached"},
+	{domain.ErrInfected, http.StatusGone, "infected"},
+	{domain.ErrNotReady, http.StatusConflict, "scan_pending"},
+	{domain.ErrTooLarge, http.StatusRequestEntityTooLarge, "file_too_large"},
+	{domain.ErrUnsupportedType, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	{domain.ErrQuotaExceeded, http.StatusRequestEntityTooLarge, "quota_exceeded"},
+	{domain.ErrInsufficientStorage, http.StatusInsufficientStorage, "insufficient_storage"},
+	{domain.ErrThrottled, http.StatusTooManyRequests, "rate_limited"},
+	{domain.ErrConflict, http.StatusConflict, "conflict"},
+}
+
+// Context keys the error handler's settings are kept under
+const (
+	opaqueKey     = "shreadbox.opaque_errors"
+	tokenRouteKey = "shreadbox.token_route"
+)
+
+// tokenStatuses are the statuses that confirm a share token exists: access
+// refused, scan pending, and expired, exhausted or infected
+var tokenStatuses = map[int]bool{
+	http.StatusForbidden: true,
+	http.StatusConflict:  true,
+	http.StatusGone:      true,
+}
+
+// errTokenNotFound is what opaque errors report for unavailable shares
+var errTokenNotFound = domain.NewError(domain.ErrNotFound, "file not found")
+
+// ErrorHandler renders errors attached with c.Error as problem+json. With
+// opaque set, expired and exhausted shares are reported exactly like unknown
+// tokens, and so is every other failure on routes marked with TokenRoute
+// that would confirm a token exists, so responses never reveal whether a
+// token ever existed.
+func ErrorHandler(opaque bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(opaqueKey, opaque)
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		problem := Conceal(c, NewProblem(c.Errors.Last().Err, opaque))
+		if problem.Status == http.StatusInternalServerError {
+			log.Printf("Internal error on %s %s: %v", c.Request.Method, c.FullPath(), c.Errors.Last().Err)
+		}
+
+		c.Header("Content-Type", problemContentType)
+		c.JSON(problem.Status, problem)
+	}
+}
+
+// NewProblem maps err to its problem details
+func NewProblem(err error, opaque bool) Problem {
+	status, code := http.StatusInternalServerError, "internal_error"
+	detail := "An unexpected error occurred"
+
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.kind) {
+			status, code = mapping.status, mapping.code
+			detail = err.Error()
+			break
+		}
+	}
+
+	if opaque && status == http.StatusGone {
+		status, code = http.StatusNotFound, "not_found"
+		detail = errTokenNotFound.Error()
+	}
+
+	return Problem{
+		Type:   "urn:shreadbox:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// TokenRoute marks routes that look a share up by its token, whose
+// failures opaque errors conceal
+func TokenRoute(c *gin.Context) {
+	c.Set(tokenRouteKey, true)
+}
+
+// Conceal returns the unknown token problem in place of problem when
+// opaque errors are on and problem would confirm that the token of a
+// TokenRoute exists
+func Conceal(c *gin.Context, problem Problem) Problem {
+	if c.GetBool(opaqueKey) && c.GetBool(tokenRouteKey) && tokenStatuses[problem.Status] {
+		return NewProblem(errTokenNotFound, false)
+	}
+	return problem
+}
+
+// NotFound reports unknown routes as problems
+func NotFound(c *gin.Context) {
+	c.Error(domain.NewError(domain.ErrNotFound, "route not found"))
+}
+
+// abortWithError attaches err for ErrorHandler, which must be installed
+// ahead of the calling middleware, and stops the chain
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
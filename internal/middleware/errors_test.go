@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+	"github.com/hardiksharma/shreadbox/internal/quota"
+	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		opaque bool
+		status int
+		code   string
+		detail string
+	}{
+		{"unknown token", storage.ErrNotFound, false, http.StatusNotFound, "not_found", "file not found"},
+		{"expired", storage.ErrExpired, false, http.StatusGone, "expired", "file has expired"},
+		{"exhausted", storage.ErrDownloadLimit, false, http.StatusGone, "download_limit_reached", "download limit reached"},
+		{"expired opaque", storage.ErrExpired, true, http.StatusNotFound, "not_found", "file not found"},
+		{"exhausted opaque", storage.ErrDownloadLimit, true, http.StatusNotFound, "not_found", "file not found"},
+		{"wrapped", fmt.Errorf("lookup: %w", storage.ErrExpired), false, http.StatusGone, "expired", "lookup: file has expired"},
+		{"quota", quota.ErrQuotaExceeded, false, http.StatusRequestEntityTooLarge, "quota_exceeded", "client quota exceeded"},
+		{"capacity", quota.ErrCapacityExceeded, false, http.StatusInsufficientStorage, "insufficient_storage", "storage capacity exceeded"},
+		{"throttled", domain.NewError(domain.ErrThrottled, "slow down"), false, http.StatusTooManyRequests, "rate_limited", "slow down"},
+		{"conflict", domain.NewError(domain.ErrConflict, "alias is taken"), false, http.StatusConflict, "conflict", "alias is taken"},
+		{"internal", errors.New("disk on fire"), false, http.StatusInternalServerError, "internal_error", "An unexpected error occurred"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(ErrorHandler(tt.opaque))
+			router.GET("/", func(c *gin.Context) {
+				c.Error(tt.err)
+			})
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, tt.detail, problem.Detail)
+			assert.Equal(t, "urn:shreadbox:problem:"+tt.code, problem.Type)
+		})
+	}
+}
+
+func TestErrorHandler_WrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler(false))
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		c.Error(errors.New("late failure"))
+	})
+
+	// Errors after the response started cannot change it
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+}
+
+func TestErrorHandler_TokenRoute(t *testing.T) {
+	forbidden := domain.NewError(domain.ErrForbidden, "address not allowed")
+	pending := domain.NewError(domain.ErrNotReady, "file is being scanned")
+	tests := []struct {
+		name   string
+		err    error
+		opaque bool
+		token  bool
+		status int
+	}{
+		{"refused", forbidden, false, true, http.StatusForbidden},
+		{"refused opaque", forbidden, true, true, http.StatusNotFound},
+		{"pending opaque", pending, true, true, http.StatusNotFound},
+		{"expired opaque", storage.ErrExpired, true, true, http.StatusNotFound},
+		{"refused opaque elsewhere", forbidden, true, false, http.StatusForbidden},
+		{"invalid opaque", domain.NewError(domain.ErrInvalidInput, "bad"), true, true, http.StatusBadRequest},
+		{"throttled opaque", domain.NewError(domain.ErrThrottled, "slow down"), true, true, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(ErrorHandler(tt.opaque))
+			handlers := []gin.HandlerFunc{func(c *gin.Context) { c.Error(tt.err) }}
+			if tt.token {
+				handlers = append([]gin.HandlerFunc{TokenRoute}, handlers...)
+			}
+			router.GET("/", handlers...)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tt.status, rec.Code)
+
+			// Concealed failures are indistinguishable from unknown tokens
+			if tt.status == http.StatusNotFound {
+				var problem Problem
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+				assert.Equal(t, NewProblem(storage.ErrNotFound, false), problem)
+			}
+		})
+	}
+}
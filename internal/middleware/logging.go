@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loggedRouteKey holds the route logged in place of a path carrying a token
+const loggedRouteKey = "middleware.loggedRoute"
+
+// Logger logs requests like gin's default logger, except that paths with a
+// share token are logged as their route, e.g. "/d/:token", so access logs
+// never hold working download links
+func Logger() gin.HandlerFunc {
+	logger := gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		path := param.Path
+		if route, ok := param.Keys[loggedRouteKey].(string); ok {
+			path = route
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			path,
+			param.ErrorMessage,
+		)
+	})
+
+	return func(c *gin.Context) {
+		if route := c.FullPath(); strings.Contains(route, ":token") {
+			c.Set(loggedRouteKey, route)
+		}
+		logger(c)
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerRedactsTokens(t *testing.T) {
+	var logs bytes.Buffer
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	defer func() { gin.DefaultWriter = defaultWriter }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Logger())
+	router.GET("/d/:token", func(c *gin.Context) {})
+	router.GET("/health", func(c *gin.Context) {})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/d/7-apple-river", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.NotContains(t, logs.String(), "apple")
+	assert.Contains(t, logs.String(), `"/d/:token"`)
+	assert.Contains(t, logs.String(), `"/health"`)
+}
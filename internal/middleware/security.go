@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// SecurityHeaders sets response headers that harden the browser side of the
+// service. HSTS is only sent over TLS, as browsers ignore it on plain HTTP.
+func SecurityHeaders(hstsMaxAge time.Duration) gin.HandlerFunc {
+	hsts := fmt.Sprintf("max-age=%d; includeSubDomains", int64(hstsMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+
+		if c.Request.TLS != nil && hstsMaxAge > 0 {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+
+		c.Next()
+	}
+}
+
+// RequireClientCert rejects requests that did not present a client
+// certificate verified against the configured client CA
+func RequireClientCert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			abortWithError(c, domain.NewError(domain.ErrForbidden, "client certificate required"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdminToken rejects requests that do not send token as
+// "Authorization: Bearer <token>". An empty token rejects everything.
+func RequireAdminToken(token string) gin.HandlerFunc {
+	want := sha256.Sum256([]byte(token))
+	return func(c *gin.Context) {
+		bearer, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		got := sha256.Sum256([]byte(strings.TrimSpace(bearer)))
+		if token == "" || subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			abortWithError(c, domain.NewError(domain.ErrUnauthorized, "admin token required"))
+			return
+		}
+		c.Next()
+	}
+}
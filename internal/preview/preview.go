@@ -0,0 +1,176 @@
+// Package preview derives small previews of uploads, so recipients can see
+// what a share holds before spending a download on it. Previews are made
+// from the plaintext at upload time and stored encrypted like the file.
+//
+// PDFs are previewed as the text of their first page; office documents get
+// no preview.
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"mime"
+	"strings"
+
+	// Decoders of the image formats previews are made of
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// MaxDimension bounds the width and height of image thumbnails
+	MaxDimension = 320
+
+	// Text previews are the first page of a file: at most MaxLines lines
+	// and MaxTextBytes bytes. PDFs are cut to the same size.
+	MaxLines     = 40
+	MaxTextBytes = 4096
+
+	// maxPixels refuses images that would take too much memory to decode
+	maxPixels = 40_000_000
+)
+
+// Content types of previews
+const (
+	TypeImage = "image/png"
+	TypeText  = "text/plain; charset=utf-8"
+)
+
+// ErrUnsupported is returned for files no preview can be made of
+var ErrUnsupported = errors.New("no preview is available for this type")
+
+// errNoText is returned for PDFs whose first page has no text, such as scans
+var errNoText = errors.New("the first page of the PDF has no text")
+
+// typePDF is previewed as the text of its first page
+const typePDF = "application/pdf"
+
+// textTypes are previewed as their first page
+var textTypes = map[string]bool{
+	"text/plain":      true,
+	"text/markdown":   true,
+	"text/x-markdown": true,
+	"text/csv":        true,
+}
+
+// imageTypes are previewed as thumbnails
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Supported reports whether a preview can be made of files of contentType
+func Supported(contentType string) bool {
+	typ, _, _ := mime.ParseMediaType(contentType)
+	return textTypes[typ] || imageTypes[typ] || typ == typePDF
+}
+
+// Generate makes the preview of a file, returning it with its content type
+func Generate(contentType string, data []byte) ([]byte, string, error) {
+	typ, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case imageTypes[typ]:
+		thumbnail, err := thumbnail(data)
+		return thumbnail, TypeImage, err
+	case textTypes[typ]:
+		return firstPage(data), TypeText, nil
+	case typ == typePDF:
+		text, err := pdfPage(data)
+		return text, TypeText, err
+	default:
+		return nil, "", ErrUnsupported
+	}
+}
+
+// thumbnail scales an image to fit MaxDimension and re-encodes it as PNG,
+// which also drops metadata such as EXIF locations
+func thumbnail(data []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large to preview", config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > MaxDimension || height > MaxDimension {
+		if width >= height {
+			width, height = MaxDimension, max(1, height*MaxDimension/width)
+		} else {
+			width, height = max(1, width*MaxDimension/height), MaxDimension
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// firstPage returns the first MaxLines lines of a text, at most
+// MaxTextBytes of it
+func firstPage(data []byte) []byte {
+	if len(data) > MaxTextBytes {
+		data = data[:MaxTextBytes]
+	}
+	lines := bytes.SplitAfterN(data, []byte("\n"), MaxLines+1)
+	if len(lines) > MaxLines {
+		lines = lines[:MaxLines]
+	}
+	return bytes.ToValidUTF8(bytes.Join(lines, nil), []byte("\uFFFD"))
+}
+
+// pdfPage returns the text of the first page of a PDF, cut like firstPage.
+// Scanned pages have no text and get no preview.
+func pdfPage(data []byte) (text []byte, err error) {
+	// The parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if reader.NumPage() < 1 {
+		return nil, errNoText
+	}
+	page, err := reader.Page(1).GetPlainText(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF text: %w", err)
+	}
+
+	// Each text object starts a line, drop the blank ones this leaves
+	var lines []string
+	for _, line := range strings.Split(page, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errNoText
+	}
+	return firstPage([]byte(strings.Join(lines, "\n") + "\n")), nil
+}
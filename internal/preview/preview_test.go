@@ -0,0 +1,124 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for x := range 1000 {
+		src.Set(x, 250, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, src, nil))
+
+	data, contentType, err := Generate("image/jpeg", buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, TypeImage, contentType)
+
+	thumbnail, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, MaxDimension, MaxDimension/2), thumbnail.Bounds(), "scaled to fit, keeping the aspect ratio")
+
+	// Small images are not enlarged
+	buf.Reset()
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 20))))
+	data, _, err = Generate("image/png", buf.Bytes())
+	require.NoError(t, err)
+	thumbnail, err = png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 10, 20), thumbnail.Bounds())
+
+	_, _, err = Generate("image/png", []byte("not a png"))
+	assert.Error(t, err)
+}
+
+func TestGenerateText(t *testing.T) {
+	var text strings.Builder
+	for i := range 100 {
+		text.WriteString(strings.Repeat("x", i) + "\n")
+	}
+
+	data, contentType, err := Generate("text/csv; charset=utf-8", []byte(text.String()))
+	require.NoError(t, err)
+	assert.Equal(t, TypeText, contentType)
+	assert.Equal(t, MaxLines, bytes.Count(data, []byte("\n")))
+
+	// Long lines are cut without splitting characters
+	data, _, err = Generate("text/plain", []byte(strings.Repeat("é", MaxTextBytes)))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), MaxTextBytes+2)
+	assert.True(t, strings.HasPrefix(string(data), "éé"))
+}
+
+// buildPDF returns a PDF with a page for each content stream
+func buildPDF(pages ...string) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	var kids []string
+	for _, content := range pages {
+		page := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R >> >> >>", page+1, page+2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+			"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestGeneratePDF(t *testing.T) {
+	document := buildPDF(
+		"BT /F1 12 Tf 72 720 Td (Quarterly report) Tj 0 -14 Td 14 TL T* (Revenue grew) Tj ET",
+		"BT /F1 12 Tf 72 720 Td (Appendix) Tj ET",
+	)
+
+	// Only the first page is previewed, as text
+	data, contentType, err := Generate("application/pdf", document)
+	require.NoError(t, err)
+	assert.Equal(t, TypeText, contentType)
+	assert.Equal(t, "Quarterly report\nRevenue grew\n", string(data))
+
+	// Pages without text, such as scans, and broken files get no preview
+	_, _, err = Generate("application/pdf", buildPDF("0 0 612 792 re f"))
+	assert.ErrorIs(t, err, errNoText)
+	_, _, err = Generate("application/pdf", []byte("%PDF-1.7"))
+	assert.Error(t, err)
+	_, _, err = Generate("application/pdf", document[:len(document)/2])
+	assert.Error(t, err)
+}
+
+func TestSupported(t *testing.T) {
+	assert.True(t, Supported("image/webp"))
+	assert.True(t, Supported("text/markdown; charset=utf-8"))
+	assert.True(t, Supported("application/pdf"))
+	assert.False(t, Supported("image/svg+xml"))
+	assert.False(t, Supported("application/vnd.oasis.opendocument.text"))
+
+	_, _, err := Generate("application/zip", []byte("PK"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
@@ -0,0 +1,41 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	link := "https://files.example.com/d/7-apple-river"
+
+	data, err := Render(link, FormatPNG, 300)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+
+	data, err = Render(link, FormatSVG, DefaultSize)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 `)))
+	assert.Regexp(t, regexp.MustCompile(`<path fill="#000" d="(M\d+ \d+h\d+v1h-\d+z)+"/></svg>$`), string(data))
+
+	_, err = Render(link, "gif", DefaultSize)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	_, err = Render(link, FormatPNG, MaxSize+1)
+	assert.ErrorIs(t, err, ErrInvalidSize)
+}
+
+func TestSVG(t *testing.T) {
+	// Runs of dark modules become one rectangle each
+	bitmap := [][]bool{
+		{true, true, false},
+		{false, true, true},
+		{true, false, true},
+	}
+	assert.Contains(t, string(svg(bitmap)), `d="M0 0h2v1h-2zM1 1h2v1h-2zM0 2h1v1h-1zM2 2h1v1h-1z"`)
+}
@@ -0,0 +1,105 @@
+package quota
+
+import (
+	"sync"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+var (
+	ErrQuotaExceeded    = domain.NewError(domain.ErrQuotaExceeded, "client quota exceeded")
+	ErrCapacityExceeded = domain.NewError(domain.ErrInsufficientStorage, "storage capacity exceeded")
+)
+
+// Limits defines the quota ceilings, a zero value means unlimited
+type Limits struct {
+	ClientBytes  int64 // stored bytes per client
+	ClientShares int   // active shares per client
+	Capacity     int64 // stored bytes across all clients
+}
+
+// Usage represents the resources held by a single client
+type Usage struct {
+	Bytes  int64 `json:"bytes"`
+	Shares int   `json:"shares"`
+}
+
+// Manager tracks storage usage per client identity and globally
+type Manager struct {
+	limits  Limits
+	clients map[string]*Usage
+	total   int64
+	mu      sync.Mutex
+}
+
+// NewManager creates a new quota manager
+func NewManager(limits Limits) *Manager {
+	return &Manager{
+		limits:  limits,
+		clients: make(map[string]*Usage),
+	}
+}
+
+// Reserve accounts a new share of the given size to client, or returns
+// ErrCapacityExceeded / ErrQuotaExceeded without reserving anything
+func (m *Manager) Reserve(client string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.limits.Capacity > 0 && m.total+size > m.limits.Capacity {
+		return ErrCapacityExceeded
+	}
+
+	usage := m.clients[client]
+	if usage == nil {
+		usage = &Usage{}
+	}
+	if m.limits.ClientBytes > 0 && usage.Bytes+size > m.limits.ClientBytes {
+		return ErrQuotaExceeded
+	}
+	if m.limits.ClientShares > 0 && usage.Shares+1 > m.limits.ClientShares {
+		return ErrQuotaExceeded
+	}
+
+	usage.Bytes += size
+	usage.Shares++
+	m.clients[client] = usage
+	m.total += size
+	return nil
+}
+
+// Release returns a share previously reserved by Reserve
+func (m *Manager) Release(client string, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	usage, exists := m.clients[client]
+	if !exists {
+		return
+	}
+
+	usage.Bytes -= size
+	usage.Shares--
+	m.total -= size
+	if usage.Shares <= 0 {
+		delete(m.clients, client)
+	}
+}
+
+// Usage returns the resources currently held by client
+func (m *Manager) Usage(client string) Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if usage, exists := m.clients[client]; exists {
+		return *usage
+	}
+	return Usage{}
+}
+
+// Total returns the stored bytes across all clients
+func (m *Manager) Total() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
@@ -0,0 +1,71 @@
+package quota
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Unlimited(t *testing.T) {
+	manager := NewManager(Limits{})
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, manager.Reserve("client", 1<<20))
+	}
+	assert.Equal(t, Usage{Bytes: 100 << 20, Shares: 100}, manager.Usage("client"))
+	assert.Equal(t, int64(100<<20), manager.Total())
+}
+
+func TestManager_ClientLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		sizes  []int64
+		errAt  int
+	}{
+		{"byte limit", Limits{ClientBytes: 100}, []int64{60, 40, 1}, 2},
+		{"share limit", Limits{ClientShares: 2}, []int64{1, 1, 1}, 2},
+		{"capacity", Limits{Capacity: 50}, []int64{25, 25, 1}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(tt.limits)
+			for i, size := range tt.sizes {
+				err := manager.Reserve("client", size)
+				if i == tt.errAt {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+		})
+	}
+}
+
+func TestManager_ErrorKinds(t *testing.T) {
+	manager := NewManager(Limits{ClientBytes: 100, Capacity: 150})
+
+	assert.NoError(t, manager.Reserve("a", 100))
+	assert.ErrorIs(t, manager.Reserve("a", 1), ErrQuotaExceeded)
+
+	// Another client has its own quota but shares the capacity
+	assert.NoError(t, manager.Reserve("b", 50))
+	assert.ErrorIs(t, manager.Reserve("b", 1), ErrCapacityExceeded)
+}
+
+func TestManager_Release(t *testing.T) {
+	manager := NewManager(Limits{ClientBytes: 100, ClientShares: 1})
+
+	assert.NoError(t, manager.Reserve("client", 100))
+	assert.Error(t, manager.Reserve("client", 1))
+
+	manager.Release("client", 100)
+	assert.Equal(t, Usage{}, manager.Usage("client"))
+	assert.Equal(t, int64(0), manager.Total())
+	assert.NoError(t, manager.Reserve("client", 100))
+
+	// Releasing an unknown client is a no-op
+	manager.Release("unknown", 10)
+	assert.Equal(t, int64(100), manager.Total())
+}
@@ -0,0 +1,54 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// CommandScanner scans files by piping them to an external command such as
+// "clamscan --no-summary -". Exit status 0 means clean, 1 means infected
+// and anything else is an error, following the clamscan convention.
+type CommandScanner struct {
+	name string
+	args []string
+}
+
+// NewCommandScanner creates a scanner running the given command line
+func NewCommandScanner(command string) (*CommandScanner, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("scan command is empty")
+	}
+	return &CommandScanner{
+		name: fields[0],
+		args: fields[1:],
+	}, nil
+}
+
+// Scan runs the command with the file on stdin
+func (s *CommandScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.name, s.args...)
+	cmd.Stdin = r
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err == nil {
+		return Result{}, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return Result{
+			Infected:  true,
+			Signature: strings.TrimSpace(stdout.String()),
+		}, nil
+	}
+	return Result{}, fmt.Errorf("scan command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+}
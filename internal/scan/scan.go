@@ -0,0 +1,17 @@
+package scan
+
+import (
+	"context"
+	"io"
+)
+
+// Result is the outcome of scanning a file
+type Result struct {
+	Infected  bool
+	Signature string // name of the detected threat, if any
+}
+
+// Scanner inspects file contents for malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eicar is the standard antivirus test string
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// startFakeClamd serves the clamd INSTREAM protocol on a Unix socket,
+// flagging any stream that contains the EICAR string
+func startFakeClamd(t *testing.T, reply func(data []byte) string) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				command := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, conn, int64(size)); err != nil {
+						return
+					}
+				}
+				conn.Write([]byte(reply(data.Bytes()) + "\x00"))
+			}(conn)
+		}
+	}()
+
+	return "unix:" + socket
+}
+
+func TestClamdScanner(t *testing.T) {
+	address := startFakeClamd(t, func(data []byte) string {
+		if bytes.Contains(data, []byte(eicar)) {
+			return "stream: Eicar-Test-Signature FOUND"
+		}
+		return "stream: OK"
+	})
+	scanner := NewClamdScanner(address)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Clean files spanning several chunks
+	result, err := scanner.Scan(ctx, bytes.NewReader(bytes.Repeat([]byte("clean"), chunkSize)))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = scanner.Scan(ctx, strings.NewReader("prefix "+eicar))
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Eicar-Test-Signature", result.Signature)
+}
+
+func TestClamdScanner_Errors(t *testing.T) {
+	address := startFakeClamd(t, func([]byte) string {
+		return "INSTREAM size limit exceeded. ERROR"
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := NewClamdScanner(address).Scan(ctx, strings.NewReader("data"))
+	assert.Error(t, err)
+
+	// Unreachable daemon
+	_, err = NewClamdScanner("unix:"+filepath.Join(t.TempDir(), "missing.sock")).Scan(ctx, strings.NewReader("data"))
+	assert.Error(t, err)
+}
+
+func TestNewClamdScanner_Address(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+	}{
+		{"unix:/run/clamd.sock", "unix", "/run/clamd.sock"},
+		{"tcp:127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+	}
+
+	for _, tt := range tests {
+		scanner := NewClamdScanner(tt.address)
+		assert.Equal(t, tt.network, scanner.network, tt.address)
+		assert.Equal(t, tt.addr, scanner.address, tt.address)
+	}
+}
+
+func TestCommandScanner(t *testing.T) {
+	// A stand-in scanner flagging stdin containing "EICAR"
+	script := `grep -q EICAR && { echo Test-Signature; exit 1; } || exit 0`
+	scanner := &CommandScanner{name: "sh", args: []string{"-c", script}}
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader("harmless"))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(eicar))
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Test-Signature", result.Signature)
+
+	failing := &CommandScanner{name: "sh", args: []string{"-c", "cat >/dev/null; echo broken >&2; exit 2"}}
+	_, err = failing.Scan(context.Background(), strings.NewReader("data"))
+	assert.ErrorContains(t, err, "broken")
+
+	_, err = NewCommandScanner("   ")
+	assert.Error(t, err)
+}
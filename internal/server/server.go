@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server wraps an http.Server with readiness tracking and graceful shutdown
+type Server struct {
+	httpServer      *http.Server
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	ready           atomic.Bool
+}
+
+// New creates a new server listening on addr
+func New(addr string, handler http.Handler, shutdownTimeout time.Duration) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+// EnableTLS makes the server terminate TLS using the given configuration
+func (s *Server) EnableTLS(tlsConfig *tls.Config) {
+	s.httpServer.TLSConfig = tlsConfig
+}
+
+// SetDrainDelay makes shutdown report not ready for delay before it stops
+// accepting connections, so load balancers polling /ready see the 503 and
+// stop routing here while the server still answers
+func (s *Server) SetDrainDelay(delay time.Duration) {
+	s.drainDelay = delay
+}
+
+// Ready reports whether the server is accepting new traffic
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// ReadyHandler responds with 200 while the server accepts traffic and 503
+// once shutdown has begun, so load balancers stop routing to it
+func (s *Server) ReadyHandler(c *gin.Context) {
+	if !s.Ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// Run serves until ctx is cancelled, then reports not ready for the drain
+// delay, stops accepting new connections and waits up to the shutdown
+// timeout for in-flight transfers to finish
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve is like Run but accepts connections on an existing listener
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	errChan := make(chan error, 1)
+	go func() {
+		if s.httpServer.TLSConfig != nil {
+			// Certificates come from TLSConfig.GetCertificate
+			errChan <- s.httpServer.ServeTLS(listener, "", "")
+			return
+		}
+		errChan <- s.httpServer.Serve(listener)
+	}()
+	s.ready.Store(true)
+
+	select {
+	case err := <-errChan:
+		s.ready.Store(false)
+		return err
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	if s.drainDelay > 0 {
+		log.Printf("Shutting down, reporting not ready for %s before draining", s.drainDelay)
+		select {
+		case err := <-errChan:
+			return err
+		case <-time.After(s.drainDelay):
+		}
+	}
+	log.Printf("Shutting down, draining in-flight requests for up to %s", s.shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		// Drain timed out; cut off whatever is still running
+		s.httpServer.Close()
+		return fmt.Errorf("failed to drain connections: %w", err)
+	}
+
+	if err := <-errChan; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
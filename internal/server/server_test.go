@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(srv **Server, release <-chan struct{}, started chan<- struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ready", func(c *gin.Context) { (*srv).ReadyHandler(c) })
+	router.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+	return router
+}
+
+func TestServer_DrainsInFlightRequests(t *testing.T) {
+	var srv *Server
+	release := make(chan struct{})
+	started := make(chan struct{})
+	srv = New("127.0.0.1:0", newTestRouter(&srv, release, started), 5*time.Second)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	baseURL := "http://" + listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, listener) }()
+
+	// Without keep-alives no idle or half-opened connection is left for
+	// Shutdown to wait on
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	// Server reports ready while serving
+	resp, err := client.Get(baseURL + "/ready")
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Start a request that is still running when shutdown begins
+	result := make(chan string, 1)
+	go func() {
+		resp, err := client.Get(baseURL + "/slow")
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+	<-started
+
+	cancel()
+	assert.Eventually(t, func() bool { return !srv.Ready() }, time.Second, 10*time.Millisecond)
+
+	// The in-flight request completes before Serve returns
+	close(release)
+	assert.Equal(t, "done", <-result)
+	assert.NoError(t, <-done)
+}
+
+func TestServer_DrainTimeout(t *testing.T) {
+	var srv *Server
+	release := make(chan struct{})
+	started := make(chan struct{})
+	defer close(release)
+	srv = New("127.0.0.1:0", newTestRouter(&srv, release, started), 50*time.Millisecond)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, listener) }()
+
+	go http.Get("http://" + listener.Addr().String() + "/slow")
+	<-started
+
+	cancel()
+	assert.Error(t, <-done)
+}
+
+func TestServer_DrainDelay(t *testing.T) {
+	var srv *Server
+	srv = New("127.0.0.1:0", newTestRouter(&srv, nil, nil), 5*time.Second)
+	srv.SetDrainDelay(300 * time.Millisecond)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	baseURL := "http://" + listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, listener) }()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	ready := func() int {
+		resp, err := client.Get(baseURL + "/ready")
+		if err != nil {
+			return 0
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, ready())
+
+	// Load balancers polling /ready see the 503 before connections are refused
+	shutdown := time.Now()
+	cancel()
+	assert.Eventually(t, func() bool { return !srv.Ready() }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, ready())
+
+	require.NoError(t, <-done)
+	assert.GreaterOrEqual(t, time.Since(shutdown), 300*time.Millisecond)
+	assert.Zero(t, ready())
+}
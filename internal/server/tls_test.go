@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// generateCert creates a certificate signed by parent, or a self-signed CA if parent is nil
+func generateCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeCert(t *testing.T, dir string, c *testCert) (string, string) {
+	t.Helper()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, c.certPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM, 0600))
+	return certFile, keyFile
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	first := generateCert(t, "first", nil)
+	certFile, keyFile := writeCert(t, dir, first)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.cert.Raw, cert.Certificate[0])
+
+	// Unchanged files are not reloaded
+	require.NoError(t, reloader.Reload())
+	cert, _ = reloader.GetCertificate(nil)
+	assert.Equal(t, first.cert.Raw, cert.Certificate[0])
+
+	// Replacing the files swaps the served certificate
+	second := generateCert(t, "second", nil)
+	writeCert(t, dir, second)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+
+	require.NoError(t, reloader.Reload())
+	cert, _ = reloader.GetCertificate(nil)
+	assert.Equal(t, second.cert.Raw, cert.Certificate[0])
+
+	// A broken certificate keeps the previous one in service
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0600))
+	later := future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	assert.Error(t, reloader.Reload())
+	cert, _ = reloader.GetCertificate(nil)
+	assert.Equal(t, second.cert.Raw, cert.Certificate[0])
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := generateCert(t, "test-ca", nil)
+	serverCert := generateCert(t, "127.0.0.1", ca)
+	clientCert := generateCert(t, "admin", ca)
+
+	certFile, keyFile := writeCert(t, dir, serverCert)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0600))
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	tlsConfig, err := NewTLSConfig(reloader, caFile)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/whoami", func(c *gin.Context) {
+		if len(c.Request.TLS.VerifiedChains) == 0 {
+			c.String(http.StatusOK, "anonymous")
+			return
+		}
+		c.String(http.StatusOK, c.Request.TLS.VerifiedChains[0][0].Subject.CommonName)
+	})
+
+	srv := New("127.0.0.1:0", router, time.Second)
+	srv.EnableTLS(tlsConfig)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Serve(ctx, listener)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	url := "https://" + listener.Addr().String() + "/whoami"
+
+	get := func(certs []tls.Certificate) string {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		return string(body[:n])
+	}
+
+	// Client certificates are optional at the TLS layer
+	assert.Equal(t, "anonymous", get(nil))
+
+	pair, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", get([]tls.Certificate{pair}))
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsPort string
+		host      string
+		expected  string
+	}{
+		{"custom port", "8443", "example.com:8080", "https://example.com:8443/api/status/abc?x=1"},
+		{"default port", "443", "example.com", "https://example.com/api/status/abc?x=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/status/abc?x=1", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+
+			RedirectHandler(tt.httpsPort).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package sharetoken
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+var ErrTooManyAttempts = domain.NewError(domain.ErrThrottled, "too many unknown share tokens, try again later")
+
+// Limiter blocks clients that look up too many unknown tokens, which keeps
+// short word codes from being guessed
+type Limiter struct {
+	maxFailures int
+	window      time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*failures
+	now      func() time.Time
+}
+
+// failures of one client in the current window
+type failures struct {
+	count int
+	start time.Time
+}
+
+// NewLimiter creates a limiter allowing maxFailures unknown tokens per
+// client within window. It returns nil, which allows everything, if
+// maxFailures is 0.
+func NewLimiter(maxFailures int, window time.Duration) *Limiter {
+	if maxFailures <= 0 || window <= 0 {
+		return nil
+	}
+	return &Limiter{
+		maxFailures: maxFailures,
+		window:      window,
+		failures:    make(map[string]*failures),
+		now:         time.Now,
+	}
+}
+
+// Allow returns ErrTooManyAttempts if client has used up its failures
+func (l *Limiter) Allow(client string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.failures[client]
+	if !ok || l.now().Sub(f.start) >= l.window {
+		return nil
+	}
+	if f.count >= l.maxFailures {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// Fail records an unknown token looked up by client
+func (l *Limiter) Fail(client string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	f, ok := l.failures[client]
+	if !ok || now.Sub(f.start) >= l.window {
+		l.prune(now)
+		l.failures[client] = &failures{count: 1, start: now}
+		return
+	}
+	f.count++
+}
+
+// prune forgets clients whose window has passed, the caller must hold the lock
+func (l *Limiter) prune(now time.Time) {
+	for client, f := range l.failures {
+		if now.Sub(f.start) >= l.window {
+			delete(l.failures, client)
+		}
+	}
+}
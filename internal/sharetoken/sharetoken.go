@@ -0,0 +1,130 @@
+// Package sharetoken generates the public tokens shares are downloaded by.
+// Tokens are only bearer secrets in links; shares are stored under separate
+// IDs, so the token format can change without touching the disk.
+package sharetoken
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+)
+
+// Token formats
+const (
+	FormatUUID   = "uuid"   // random UUIDs, 122 bits
+	FormatBase58 = "base58" // 22 base58 characters, about 128 bits
+	FormatWords  = "words"  // a number and words, e.g. "7-apple-river"
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	base58Length   = 22
+
+	// Word codes start with a number from 2 to 99, the rest is words
+	MinWords     = 2
+	MaxWords     = 8
+	DefaultWords = 3
+)
+
+var (
+	ErrInvalidAlias = domain.NewError(domain.ErrInvalidInput,
+		"alias must be 4 to 64 lowercase letters, digits or hyphens, starting and ending with a letter or digit")
+
+	aliasPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{2,62}[a-z0-9]$`)
+)
+
+// Generator creates tokens in one format
+type Generator struct {
+	format string
+	words  int
+}
+
+// NewGenerator creates a generator for format, empty meaning uuid. words is
+// the number of words in word codes, 0 for the default.
+func NewGenerator(format string, words int) (*Generator, error) {
+	switch format {
+	case "":
+		format = FormatUUID
+	case FormatUUID, FormatBase58:
+	case FormatWords:
+		if words == 0 {
+			words = DefaultWords
+		}
+		if words < MinWords || words > MaxWords {
+			return nil, fmt.Errorf("word codes need %d to %d words, got %d", MinWords, MaxWords, words)
+		}
+	default:
+		return nil, fmt.Errorf("unknown token format %q", format)
+	}
+	return &Generator{format: format, words: words}, nil
+}
+
+// Format returns the generator's token format
+func (g *Generator) Format() string {
+	return g.format
+}
+
+// Generate returns a new random token
+func (g *Generator) Generate() (string, error) {
+	switch g.format {
+	case FormatBase58:
+		token := make([]byte, base58Length)
+		for i := range token {
+			n, err := randInt(len(base58Alphabet))
+			if err != nil {
+				return "", err
+			}
+			token[i] = base58Alphabet[n]
+		}
+		return string(token), nil
+	case FormatWords:
+		n, err := randInt(98)
+		if err != nil {
+			return "", err
+		}
+		parts := []string{strconv.Itoa(n + 2)}
+		for range g.words {
+			n, err := randInt(len(wordList))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, wordList[n])
+		}
+		return strings.Join(parts, "-"), nil
+	default:
+		return uuid.New().String(), nil
+	}
+}
+
+// Normalize canonicalizes a token typed by a person: lowercase, with runs
+// of spaces, dots and underscores turned into single hyphens, so
+// "7 Apple River" finds "7-apple-river". Base58 tokens are case sensitive
+// and must be looked up exactly before trying this.
+func Normalize(token string) string {
+	fields := strings.FieldsFunc(strings.ToLower(token), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_' || r == '.' || r == '\t'
+	})
+	return strings.Join(fields, "-")
+}
+
+// ValidateAlias checks a custom alias chosen by an uploader
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) || strings.Contains(alias, "--") {
+		return ErrInvalidAlias
+	}
+	return nil
+}
+
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate token: %w", err)
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,86 @@
+package sharetoken
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator(t *testing.T) {
+	tests := []struct {
+		format string
+		words  int
+		want   *regexp.Regexp
+	}{
+		{"", 0, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`)},
+		{FormatBase58, 0, regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{22}$`)},
+		{FormatWords, 0, regexp.MustCompile(`^([2-9]|[1-9][0-9])(-[a-z]+){3}$`)},
+		{FormatWords, 5, regexp.MustCompile(`^([2-9]|[1-9][0-9])(-[a-z]+){5}$`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			generator, err := NewGenerator(tt.format, tt.words)
+			require.NoError(t, err)
+
+			seen := make(map[string]bool)
+			for range 100 {
+				token, err := generator.Generate()
+				require.NoError(t, err)
+				assert.Regexp(t, tt.want, token)
+				if tt.format != FormatBase58 {
+					assert.Equal(t, token, Normalize(token), "generated tokens are canonical")
+				}
+				seen[token] = true
+			}
+			assert.Greater(t, len(seen), 95)
+		})
+	}
+
+	_, err := NewGenerator("emoji", 0)
+	assert.Error(t, err)
+	_, err = NewGenerator(FormatWords, 1)
+	assert.Error(t, err)
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "7-apple-river", Normalize("7 Apple  River"))
+	assert.Equal(t, "7-apple-river", Normalize(" 7_apple.river "))
+	assert.Equal(t, "7-apple-river", Normalize("7--apple-river"))
+}
+
+func TestValidateAlias(t *testing.T) {
+	for _, alias := range []string{"q3-report", "team2024", "abcd"} {
+		assert.NoError(t, ValidateAlias(alias), alias)
+	}
+	for _, alias := range []string{"abc", "Q3-report", "-report", "report-", "q3--report", "q3 report", "rapport/2024"} {
+		assert.ErrorIs(t, ValidateAlias(alias), ErrInvalidAlias, alias)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(3, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for range 3 {
+		assert.NoError(t, limiter.Allow("1.2.3.4"))
+		limiter.Fail("1.2.3.4")
+	}
+	assert.ErrorIs(t, limiter.Allow("1.2.3.4"), ErrTooManyAttempts)
+	assert.NoError(t, limiter.Allow("5.6.7.8"), "other clients are unaffected")
+
+	// The block lifts once the window has passed
+	now = now.Add(time.Minute)
+	assert.NoError(t, limiter.Allow("1.2.3.4"))
+	limiter.Fail("5.6.7.8")
+	assert.NotContains(t, limiter.failures, "1.2.3.4", "stale clients are forgotten")
+
+	// A nil limiter allows everything
+	disabled := NewLimiter(0, time.Minute)
+	disabled.Fail("1.2.3.4")
+	assert.NoError(t, disabled.Allow("1.2.3.4"))
+}
@@ -0,0 +1,39 @@
+package sharetoken
+
+// wordList holds the words of word codes: short, common, easy to spell and
+// to tell apart when read aloud. Its 256 words give each word 8 bits.
+var wordList = [...]string{
+	"acid", "acorn", "actor", "adobe", "agent", "alarm", "album", "alley",
+	"amber", "angle", "apple", "apron", "arena", "arrow", "aspen", "atlas",
+	"attic", "audio", "award", "bacon", "badge", "bagel", "baker", "banjo",
+	"baron", "basin", "beach", "beard", "berry", "bingo", "birch", "bison",
+	"blade", "blaze", "bloom", "board", "bonus", "boots", "brain", "brass",
+	"bread", "brick", "bride", "brook", "brush", "bugle", "cabin", "cable",
+	"camel", "candy", "canoe", "cargo", "cedar", "chalk", "charm", "chess",
+	"chief", "cider", "clerk", "cliff", "cloud", "coast", "cobra", "cocoa",
+	"comet", "coral", "couch", "crane", "creek", "crown", "cube", "daisy",
+	"dance", "delta", "denim", "diary", "dingo", "disco", "dragon", "dream",
+	"drum", "eagle", "easel", "echo", "elbow", "ember", "engine", "falcon",
+	"fancy", "farm", "feast", "fence", "ferry", "fiber", "field", "flame",
+	"flute", "focus", "forest", "fossil", "fox", "frost", "fudge", "garden",
+	"gecko", "ghost", "ginger", "globe", "goat", "grape", "gravel", "guitar",
+	"hammer", "harbor", "hazel", "helmet", "heron", "hill", "honey", "horse",
+	"hotel", "igloo", "index", "iris", "island", "ivory", "jacket", "jaguar",
+	"jelly", "jewel", "jungle", "kayak", "kettle", "kiwi", "koala", "ladder",
+	"lagoon", "lamp", "lemon", "lily", "lion", "locket", "lotus", "magnet",
+	"mango", "maple", "marble", "meadow", "melon", "metal", "mint", "mirror",
+	"monkey", "moon", "moose", "motor", "muffin", "museum", "nectar", "needle",
+	"nest", "noodle", "ocean", "olive", "onion", "opal", "orbit", "orchid",
+	"otter", "owl", "oyster", "paddle", "palace", "panda", "paper", "parrot",
+	"pasta", "peach", "pearl", "pebble", "pencil", "pepper", "piano", "pilot",
+	"pine", "planet", "plum", "pocket", "polar", "pony", "poppy", "potato",
+	"prism", "puzzle", "quail", "quartz", "quill", "rabbit", "radio", "rain",
+	"raven", "reef", "ribbon", "river", "robin", "rocket", "rose", "ruby",
+	"saddle", "salmon", "sand", "satin", "scarf", "shadow", "shell", "silver",
+	"skate", "sled", "snow", "sofa", "spice", "spider", "spoon", "spring",
+	"squid", "stone", "storm", "sugar", "summer", "sunset", "swan", "table",
+	"tango", "tiger", "timber", "toast", "tomato", "topaz", "tower", "tulip",
+	"tunnel", "turtle", "valley", "velvet", "violet", "wagon", "walnut",
+	"whale", "willow", "window", "winter", "wolf", "yacht", "yogurt", "zebra",
+	"zinc",
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// blobPrefix names blobs shared by deduplicated shares, other blobs are
+// named after their share
+const blobPrefix = "blob-"
+
+// sharedBlob is a blob referenced by every deduplicated share of one file
+type sharedBlob struct {
+	path string
+	size int64
+	refs int
+}
+
+// blobPath returns where the shared blob with the given ID is stored
+func (s *Storage) blobPath(blobID string) string {
+	return filepath.Join(s.basePath, blobPrefix+blobID)
+}
+
+// sharedBlobID returns the blob ID of a shared blob's file name
+func sharedBlobID(name string) (string, bool) {
+	blobID, ok := strings.CutPrefix(name, blobPrefix)
+	if !ok || len(blobID) != 64 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(blobID); err != nil {
+		return "", false
+	}
+	return blobID, true
+}
+
+// attachBlob points a deduplicated share at the existing blob for its
+// content and takes a reference, reporting false if there is none yet. The
+// caller must hold the storage lock.
+func (s *Storage) attachBlob(metadata *FileMetadata) bool {
+	blob, exists := s.blobs[metadata.BlobID]
+	if !exists {
+		return false
+	}
+
+	blob.refs++
+	metadata.FilePath = blob.path
+	metadata.StoredSize = blob.size
+	return true
+}
+
+// releaseBlob drops a share's reference to its shared blob, shredding the
+// blob with the last reference. The caller must hold the storage lock.
+func (s *Storage) releaseBlob(metadata *FileMetadata) error {
+	blob, exists := s.blobs[metadata.BlobID]
+	if !exists || blob.path != metadata.FilePath {
+		return nil
+	}
+	if blob.refs > 1 {
+		blob.refs--
+		return nil
+	}
+
+	if err := shredFile(blob.path); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	delete(s.blobs, metadata.BlobID)
+	s.totalBytes -= blob.size
+	return nil
+}
+
+// detachBlob drops a share's reference to its shared blob, leaving the share
+// without content. The caller must hold the storage lock.
+func (s *Storage) detachBlob(metadata *FileMetadata) error {
+	if err := s.releaseBlob(metadata); err != nil {
+		return err
+	}
+	metadata.BlobID = ""
+	metadata.FilePath = ""
+	return nil
+}
+
+// blobShares returns the IDs of the shares referencing a shared blob. The
+// caller must hold the storage lock.
+func (s *Storage) blobShares(blobID string) []string {
+	var ids []string
+	for id, metadata := range s.files {
+		if metadata.BlobID == blobID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// condemnBlob shreds a shared blob outright, marking every share of it with
+// the verdict that condemned it. The caller must hold the storage lock.
+func (s *Storage) condemnBlob(blobID, status, signature string) error {
+	blob, exists := s.blobs[blobID]
+	if !exists {
+		return nil
+	}
+	if err := shredFile(blob.path); err != nil {
+		return fmt.Errorf("failed to shred file: %w", err)
+	}
+	delete(s.blobs, blobID)
+	s.totalBytes -= blob.size
+
+	// Later uploads of the same file get a blob of their own
+	var errs []error
+	for _, metadata := range s.files {
+		if metadata.BlobID == blobID {
+			metadata.ScanStatus = status
+			metadata.ScanSignature = signature
+			metadata.BlobID = ""
+			metadata.FilePath = ""
+			if err := s.shredPreview(metadata); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
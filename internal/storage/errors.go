@@ -0,0 +1,18 @@
+package storage
+
+import "github.com/hardiksharma/shreadbox/internal/domain"
+
+var (
+	ErrNotFound      = domain.NewError(domain.ErrNotFound, "file not found")
+	ErrExpired       = domain.NewError(domain.ErrExpired, "file has expired")
+	ErrDownloadLimit = domain.NewError(domain.ErrExhausted, "download limit reached")
+	ErrScanPending   = domain.NewError(domain.ErrNotReady, "file is still being scanned")
+	ErrInfected      = domain.NewError(domain.ErrInfected, "file was destroyed by the malware scan")
+	ErrTokenTaken    = domain.NewError(domain.ErrConflict, "alias is already in use")
+	ErrNoPreview     = domain.NewError(domain.ErrNotFound, "no preview is available for this file")
+	ErrPreviewLimit  = domain.NewError(domain.ErrExhausted, "preview limit reached")
+
+	ErrRequestNotFound = domain.NewError(domain.ErrNotFound, "file request not found")
+	ErrRequestExpired  = domain.NewError(domain.ErrExpired, "file request has expired")
+	ErrRequestFull     = domain.NewError(domain.ErrExpired, "file request is no longer accepting uploads")
+)
@@ -0,0 +1,50 @@
+package storage
+
+import "time"
+
+// EventType identifies what happened to a share
+type EventType string
+
+const (
+	EventDownloaded EventType = "downloaded"
+	EventExpired    EventType = "expired"
+	EventExhausted  EventType = "exhausted"
+	EventRevoked    EventType = "revoked"
+	EventCorrupted  EventType = "corrupted" // blob missing or damaged, found by Reconcile
+)
+
+// Removed reports whether the event destroyed the share
+func (t EventType) Removed() bool {
+	return t == EventExpired || t == EventExhausted || t == EventRevoked || t == EventCorrupted
+}
+
+// Event describes a change to a share. File is a snapshot taken when the
+// event occurred.
+type Event struct {
+	Type       EventType
+	File       FileMetadata
+	OccurredAt time.Time
+}
+
+// Listener receives storage events. Listeners run with the storage lock
+// held, so they must return quickly and must not call back into Storage.
+type Listener func(event Event)
+
+// Subscribe registers a listener for all storage events
+func (s *Storage) Subscribe(listener Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// emit notifies listeners, the caller must hold the storage lock
+func (s *Storage) emit(eventType EventType, metadata *FileMetadata) {
+	event := Event{
+		Type:       eventType,
+		File:       *metadata.snapshot(),
+		OccurredAt: time.Now(),
+	}
+	for _, listener := range s.listeners {
+		listener(event)
+	}
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"container/heap"
+	"errors"
+	"time"
+)
+
+// expiryEntry schedules the destruction of one share
+type expiryEntry struct {
+	id        string
+	expiresAt time.Time
+	index     int
+}
+
+// expiryQueue is a min-heap of shares ordered by ExpiresAt
+type expiryQueue []*expiryEntry
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expiresAt.Before(q[j].expiresAt) }
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *expiryQueue) Push(x any) {
+	entry := x.(*expiryEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	entry.index = -1
+	return entry
+}
+
+// CleanupStats describes a single cleanup run
+type CleanupStats struct {
+	Scanned    int           `json:"scanned"`
+	Expired    int           `json:"expired"`
+	Exhausted  int           `json:"exhausted"`
+	Requests   int           `json:"requests"` // expired file requests removed
+	BytesFreed int64         `json:"bytes_freed"`
+	Failed     int           `json:"failed"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Removed is the number of shares destroyed by the run
+func (s CleanupStats) Removed() int {
+	return s.Expired + s.Exhausted
+}
+
+// scheduleExpiry adds a share to the expiry queue, the caller must hold
+// the storage lock
+func (s *Storage) scheduleExpiry(metadata *FileMetadata) {
+	s.unscheduleExpiry(metadata.ID)
+
+	entry := &expiryEntry{id: metadata.ID, expiresAt: metadata.ExpiresAt}
+	heap.Push(&s.expiries, entry)
+	s.expiryIndex[metadata.ID] = entry
+
+	// Wake the scheduler if this share is now the next to expire
+	if entry.index == 0 {
+		select {
+		case s.expiryChanged <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// unscheduleExpiry removes a share from the expiry queue, the caller must
+// hold the storage lock
+func (s *Storage) unscheduleExpiry(id string) {
+	if entry, exists := s.expiryIndex[id]; exists {
+		heap.Remove(&s.expiries, entry.index)
+		delete(s.expiryIndex, id)
+	}
+}
+
+// NextExpiry returns when the next share expires
+func (s *Storage) NextExpiry() (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.expiries) == 0 {
+		return time.Time{}, false
+	}
+	return s.expiries[0].expiresAt, true
+}
+
+// ExpiryChanged signals when a share expiring earlier than NextExpiry
+// reported has been added
+func (s *Storage) ExpiryChanged() <-chan struct{} {
+	return s.expiryChanged
+}
+
+// ExpireDue destroys every share whose expiry time has passed. Only due
+// shares are visited, so this is cheap enough to run at each deadline.
+func (s *Storage) ExpireDue(now time.Time) (CleanupStats, error) {
+	start := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats CleanupStats
+	var errs []error
+	for len(s.expiries) > 0 && !s.expiries[0].expiresAt.After(now) {
+		entry := heap.Pop(&s.expiries).(*expiryEntry)
+		delete(s.expiryIndex, entry.id)
+		stats.Scanned++
+
+		metadata, exists := s.files[entry.id]
+		if !exists {
+			continue
+		}
+		size := metadata.StoredSize
+		if err := s.deleteFile(entry.id, EventExpired); err != nil {
+			stats.Failed++
+			errs = append(errs, err)
+			continue
+		}
+		stats.Expired++
+		stats.BytesFreed += size
+	}
+
+	stats.Duration = time.Since(start)
+	return stats, errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"crypto/hmac"
+
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
+)
+
+// manageGenerator issues management tokens, long enough that they need no
+// throttling against guessing
+var manageGenerator, _ = sharetoken.NewGenerator(sharetoken.FormatBase58, 0)
+
+// manageLabel keeps management token hashes apart from download token hashes
+const manageLabel = "manage:"
+
+// assignManageToken gives a new share its management token. Only the
+// token's hash is kept. The caller must hold the lock.
+func (s *Storage) assignManageToken(metadata *FileMetadata) error {
+	token, err := manageGenerator.Generate()
+	if err != nil {
+		return err
+	}
+	metadata.ManageToken, metadata.ManageHash = token, s.hashToken(manageLabel+token)
+	return nil
+}
+
+// Manage returns a copy of a share's metadata to whoever holds its
+// management token. Wrong tokens get ErrNotFound, like unknown shares.
+func (s *Storage) Manage(id, token string) (*FileMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metadata, err := s.managed(id, token)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.snapshot(), nil
+}
+
+// RevokeManaged destroys a share before it expires on behalf of whoever
+// holds its management token
+func (s *Storage) RevokeManaged(id, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.managed(id, token); err != nil {
+		return err
+	}
+	return s.deleteFile(id, EventRevoked)
+}
+
+// managed returns the share id names if token manages it, the caller must
+// hold the lock
+func (s *Storage) managed(id, token string) (*FileMetadata, error) {
+	metadata, exists := s.files[id]
+	if !exists || metadata.ManageHash == "" || token == "" {
+		return nil, ErrNotFound
+	}
+	if !hmac.Equal([]byte(metadata.ManageHash), []byte(s.hashToken(manageLabel+token))) {
+		return nil, ErrNotFound
+	}
+	return metadata, nil
+}
@@ -1,27 +1,98 @@
 package storage
 
 import (
+	"slices"
 	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/access"
+)
+
+// Scan statuses of a share. Shares uploaded while scanning is disabled
+// have an empty status.
+const (
+	ScanPending  = "pending"
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+	ScanFailed   = "failed"
 )
 
 // FileMetadata represents the metadata for a stored file
 type FileMetadata struct {
 	ID            string    `json:"id"`
+	Token         string    `json:"-"` // public token in download links, only on the metadata passed to SaveFile
+	TokenHash     string    `json:"-"` // what storage keeps of the token instead
+	ManageToken   string    `json:"-"` // lets the uploader check and revoke the share, only on the metadata passed to SaveFile
+	ManageHash    string    `json:"-"` // what storage keeps of the management token instead
 	FileName      string    `json:"file_name"`
 	FilePath      string    `json:"file_path"`
+	BlobID        string    `json:"-"` // content-derived blob shared by deduplicated shares, if any
 	EncryptionKey []byte    `json:"-"` // Not exposed in JSON
+	WrappedKey    []byte    `json:"-"` // data key sealed to recipient public keys, the server cannot open it
+	Digests       []byte    `json:"-"` // plaintext digests, encrypted with the data key
 	ExpiresAt     time.Time `json:"expires_at"`
 	DownloadsLeft int       `json:"downloads_left"`
+	DownloadCount int       `json:"download_count"`
+	PreviewsLeft  int       `json:"previews_left"`
+	PreviewType   string    `json:"-"` // content type of the decrypted preview
+	PreviewPath   string    `json:"-"` // encrypted preview, empty if the share has none
+	Preview       []byte    `json:"-"` // encrypted preview to store, only on the metadata passed to SaveFile
 	Message       string    `json:"message,omitempty"`
 	ContentType   string    `json:"content_type"`
 	FileSize      int64     `json:"file_size"`
-	CreatedAt     time.Time `json:"created_at"`
+	StoredSize    int64     `json:"stored_size"` // compressed and encrypted size on disk
+	QuotaSize     int64     `json:"-"`           // reserved against the owner's quota, released when the share goes
+	Owner         string    `json:"-"`           // client identity charged for the share
+	ScanStatus    string    `json:"scan_status,omitempty"`
+	ScanSignature string    `json:"scan_signature,omitempty"`
+	NotifyURL     string    `json:"-"` // receives webhook events for this share
+	NotifySecret  string    `json:"-"` // signs webhook events for this share
+	Recipients    []string  `json:"-"` // emailed the link once the share is ready
+	UploaderEmail string    `json:"-"` // told about the first download and expiry
+	RequestID     string    `json:"-"` // file request the share was uploaded to, if any
+
+	// Access rules checked before each download
+	Access        *access.Policy  `json:"-"`
+	AccessClients []string        `json:"-"` // distinct addresses allowed so far
+	AccessDenials []access.Denial `json:"-"` // recent refused attempts, shown to the owner
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// snapshot returns a copy of the metadata that stays valid once the
+// storage lock is released. Slices updated in place by access checks are
+// cloned too.
+func (m *FileMetadata) snapshot() *FileMetadata {
+	copied := *m
+	copied.AccessClients = slices.Clone(m.AccessClients)
+	copied.AccessDenials = slices.Clone(m.AccessDenials)
+	return &copied
 }
 
 // FileUploadResponse represents the response sent back to the client after a successful upload
 type FileUploadResponse struct {
+	ID          string    `json:"id"` // names the share to its owner, never a download token
 	Token       string    `json:"token"`
 	ExpiresAt   time.Time `json:"expires_at"`
 	FileName    string    `json:"file_name"`
 	DownloadURL string    `json:"download_url"`
+
+	// ManagementToken checks and revokes the share through /api/shares,
+	// without an API key. It is shown only once.
+	ManagementToken string `json:"management_token"`
+
+	// NotifySecret verifies webhook signatures, only set with a notify_url
+	NotifySecret string `json:"notify_secret,omitempty"`
+
+	// Digests of the uploaded file by algorithm, hex encoded
+	Digests map[string]string `json:"digests"`
+
+	// Previews recipients may view, 0 when none were allowed or none could
+	// be made of the file
+	Previews int `json:"previews_allowed"`
+}
+
+// Stats represents aggregate information about stored files
+type Stats struct {
+	ActiveShares  int   `json:"active_shares"`
+	TotalBytes    int64 `json:"total_bytes"`    // stored, after compression and encryption, each blob once
+	OriginalBytes int64 `json:"original_bytes"` // uploaded, before compression
 }
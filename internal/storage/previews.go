@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// previewSuffix names the encrypted preview stored next to a share's blob
+const previewSuffix = ".preview"
+
+// previewShareID returns the share ID of a preview's file name
+func previewShareID(name string) (string, bool) {
+	id, ok := strings.CutSuffix(name, previewSuffix)
+	return id, ok && uuid.Validate(id) == nil
+}
+
+// writePreview stores the preview set on a new share, the caller must hold
+// the lock
+func (s *Storage) writePreview(metadata *FileMetadata) error {
+	path := filepath.Join(s.basePath, metadata.ID+previewSuffix)
+	if err := writeStaged(path, metadata.Preview); err != nil {
+		return fmt.Errorf("failed to save preview: %w", err)
+	}
+	metadata.PreviewPath = path
+	return nil
+}
+
+// GetPreview counts a view of a share's preview, returning a copy of the
+// share's metadata and the encrypted preview, read while the lock is held
+// like downloads. Downloads are not affected.
+func (s *Storage) GetPreview(id string) (*FileMetadata, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, exists := s.files[id]
+	if !exists {
+		return nil, nil, ErrNotFound
+	}
+	if time.Now().After(metadata.ExpiresAt) {
+		s.deleteFile(id, EventExpired)
+		return nil, nil, ErrExpired
+	}
+
+	// Previews are held back with the file until it is scanned
+	switch metadata.ScanStatus {
+	case ScanPending:
+		return nil, nil, ErrScanPending
+	case ScanInfected, ScanFailed:
+		return nil, nil, ErrInfected
+	}
+
+	if metadata.PreviewPath == "" {
+		return nil, nil, ErrNoPreview
+	}
+	if metadata.PreviewsLeft <= 0 {
+		return nil, nil, ErrPreviewLimit
+	}
+	data, err := os.ReadFile(metadata.PreviewPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read preview: %w", err)
+	}
+	metadata.PreviewsLeft--
+	return metadata.snapshot(), data, nil
+}
+
+// shredPreview destroys a share's preview, if it has one. The caller must
+// hold the lock.
+func (s *Storage) shredPreview(metadata *FileMetadata) error {
+	if metadata.PreviewPath == "" {
+		return nil
+	}
+	if err := shredFile(metadata.PreviewPath); err != nil {
+		return fmt.Errorf("failed to shred preview: %w", err)
+	}
+	metadata.PreviewPath = ""
+	return nil
+}
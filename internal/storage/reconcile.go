@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+)
+
+// quarantineDir holds damaged blobs, relative to the storage directory
+const quarantineDir = "quarantine"
+
+// maxReconcileIssues caps how many individual findings a report lists
+const maxReconcileIssues = 100
+
+// Kinds of problem found by Reconcile
+const (
+	IssueOrphan       = "orphan"        // blob without metadata, shredded
+	IssueStaging      = "staging"       // interrupted write, removed
+	IssueMissingBlob  = "missing_blob"  // metadata without a blob, dropped
+	IssueSizeMismatch = "size_mismatch" // blob size differs from metadata, quarantined
+	IssueUnreadable   = "unreadable"    // blob header cannot be read, quarantined
+)
+
+// ReconcileIssue describes one problem found by Reconcile
+type ReconcileIssue struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ReconcileReport summarises a reconciliation of the storage directory
+// with the shares held in memory
+type ReconcileReport struct {
+	StartedAt      time.Time        `json:"started_at"`
+	Duration       time.Duration    `json:"duration"`
+	BlobsScanned   int              `json:"blobs_scanned"`
+	SharesChecked  int              `json:"shares_checked"`
+	Orphans        int              `json:"orphans"`
+	Staging        int              `json:"staging"`
+	MissingBlobs   int              `json:"missing_blobs"`
+	SizeMismatches int              `json:"size_mismatches"`
+	Unreadable     int              `json:"unreadable"`
+	BytesFreed     int64            `json:"bytes_freed"`
+	Failed         int              `json:"failed"`
+	Issues         []ReconcileIssue `json:"issues,omitempty"` // the first findings, in the order found
+	Error          string           `json:"error,omitempty"`
+}
+
+// Problems is the number of inconsistencies the run found
+func (r ReconcileReport) Problems() int {
+	return r.Orphans + r.Staging + r.MissingBlobs + r.SizeMismatches + r.Unreadable
+}
+
+func (r *ReconcileReport) record(id, kind, detail string) {
+	switch kind {
+	case IssueOrphan:
+		r.Orphans++
+	case IssueStaging:
+		r.Staging++
+	case IssueMissingBlob:
+		r.MissingBlobs++
+	case IssueSizeMismatch:
+		r.SizeMismatches++
+	case IssueUnreadable:
+		r.Unreadable++
+	}
+	if len(r.Issues) < maxReconcileIssues {
+		r.Issues = append(r.Issues, ReconcileIssue{ID: id, Kind: kind, Detail: detail})
+	}
+}
+
+// Reconcile brings the storage directory and the shares held in memory
+// back in line after a crash or a failed removal. Blobs nobody can
+// download are shredded, shares whose blob is gone are dropped, and shares
+// whose blob is damaged are removed with the blob moved to quarantine for
+// inspection. Only files named like blobs are touched, so a storage
+// directory shared with other files is safe.
+func (s *Storage) Reconcile() (ReconcileReport, error) {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	report := ReconcileReport{StartedAt: time.Now()}
+	var errs []error
+
+	// Find blobs on disk without holding the lock; each is re-checked
+	// under the lock before anything is removed
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list storage directory: %w", err))
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		if blob, staged := strings.CutSuffix(name, stagingSuffix); staged {
+			if isBlobName(blob) {
+				if err := s.removeStaging(name, &report); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			continue
+		}
+		if !isBlobName(name) {
+			continue
+		}
+		report.BlobsScanned++
+		if err := s.shredOrphan(name, &report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Check every share against its blob
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.files))
+	for id := range s.files {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+
+	for _, id := range ids {
+		if err := s.checkBlob(id, &report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err = errors.Join(errs...)
+	report.Failed = len(errs)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Duration = time.Since(report.StartedAt)
+
+	s.mu.Lock()
+	s.lastReconcile = &report
+	s.mu.Unlock()
+	return report, err
+}
+
+// LastReconcile returns the report of the most recent reconciliation
+func (s *Storage) LastReconcile() (ReconcileReport, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.lastReconcile == nil {
+		return ReconcileReport{}, false
+	}
+	return *s.lastReconcile, true
+}
+
+// removeStaging removes a staging file. Writes hold the lock from start to
+// rename, so any staging file seen under the lock was abandoned.
+func (s *Storage) removeStaging(name string, report *ReconcileReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.basePath, name)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove staging file %s: %w", name, err)
+	}
+
+	report.record(strings.TrimSuffix(name, stagingSuffix), IssueStaging, "")
+	report.BytesFreed += info.Size()
+	return nil
+}
+
+// isBlobName reports whether a file in the storage directory is named like
+// a share's blob, a shared blob or a share's preview
+func isBlobName(name string) bool {
+	_, shared := sharedBlobID(name)
+	_, preview := previewShareID(name)
+	return shared || preview || uuid.Validate(name) == nil
+}
+
+// shredOrphan shreds a blob that no downloadable share refers to. Tombstones
+// of infected shares count too, their blob should already be gone.
+func (s *Storage) shredOrphan(name string, report *ReconcileReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.basePath, name)
+	if blobID, shared := sharedBlobID(name); shared {
+		if _, exists := s.blobs[blobID]; exists {
+			return nil
+		}
+	} else if id, preview := previewShareID(name); preview {
+		if metadata, exists := s.files[id]; exists && metadata.PreviewPath == path {
+			return nil
+		}
+	} else if metadata, exists := s.files[name]; exists && !metadata.tombstone() {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil {
+		err = shredFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to shred orphaned blob %s: %w", name, err)
+	}
+
+	report.record(name, IssueOrphan, "")
+	report.BytesFreed += info.Size()
+	return nil
+}
+
+// checkBlob verifies a share's blob exists, has the recorded size and a
+// readable header
+func (s *Storage) checkBlob(id string, report *ReconcileReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, exists := s.files[id]
+	if !exists || metadata.tombstone() {
+		return nil
+	}
+	report.SharesChecked++
+
+	info, err := os.Stat(metadata.FilePath)
+	if os.IsNotExist(err) {
+		report.record(id, IssueMissingBlob, "")
+		return s.dropShares(metadata)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check blob %s: %w", id, err)
+	}
+
+	if info.Size() != metadata.StoredSize {
+		report.record(id, IssueSizeMismatch, fmt.Sprintf("expected %d bytes, found %d", metadata.StoredSize, info.Size()))
+		return s.quarantine(metadata, info.Size(), report)
+	}
+	if err := checkHeader(metadata.FilePath, info.Size()); err != nil {
+		report.record(id, IssueUnreadable, err.Error())
+		return s.quarantine(metadata, info.Size(), report)
+	}
+	return nil
+}
+
+// quarantine moves a damaged blob aside and removes its shares, the caller
+// must hold the storage lock
+func (s *Storage) quarantine(metadata *FileMetadata, size int64, report *ReconcileReport) error {
+	dir := filepath.Join(s.basePath, quarantineDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	name := filepath.Base(metadata.FilePath)
+	if err := os.Rename(metadata.FilePath, filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("failed to quarantine blob %s: %w", name, err)
+	}
+
+	report.BytesFreed += size
+	return s.dropShares(metadata)
+}
+
+// dropShares removes every share stored in the same blob as metadata, the
+// caller must hold the storage lock
+func (s *Storage) dropShares(metadata *FileMetadata) error {
+	ids := []string{metadata.ID}
+	if metadata.BlobID != "" {
+		ids = s.blobShares(metadata.BlobID)
+	}
+
+	var errs []error
+	for _, id := range ids {
+		if err := s.deleteFile(id, EventCorrupted); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkHeader reads the header of an encrypted blob
+func checkHeader(path string, size int64) error {
+	if size < encryption.Overhead {
+		return fmt.Errorf("blob is %d bytes, shorter than the encryption overhead", size)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, encryption.HeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return err
+	}
+	_, err = encryption.ParseHeader(header)
+	return err
+}
+
+// tombstone reports whether the share's blob was destroyed on purpose by
+// the malware scan
+func (m *FileMetadata) tombstone() bool {
+	return m.ScanStatus == ScanInfected || m.ScanStatus == ScanFailed
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileRequest is an inbound link external parties upload files to without
+// an account. Uploads are sealed to the requester's public keys and charged
+// to the requester.
+type FileRequest struct {
+	ID             string        `json:"id"`
+	Title          string        `json:"title"`
+	Message        string        `json:"message,omitempty"`
+	Owner          string        `json:"-"`
+	RecipientKeys  string        `json:"-"` // age public keys uploads are sealed to
+	MaxFileSize    int64         `json:"max_file_size,omitempty"`
+	MaxUploads     int           `json:"max_uploads,omitempty"` // zero means unlimited
+	Uploads        int           `json:"uploads"`
+	ShareExpiry    time.Duration `json:"-"` // lifetime of each received file
+	ShareDownloads int           `json:"-"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// Open reports whether the request still accepts uploads
+func (r *FileRequest) Open(now time.Time) bool {
+	return now.Before(r.ExpiresAt) && (r.MaxUploads == 0 || r.Uploads < r.MaxUploads)
+}
+
+// CreateRequest stores a new file request
+func (s *Storage) CreateRequest(request *FileRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request.ID = uuid.New().String()
+	request.CreatedAt = time.Now()
+	s.requests[request.ID] = request
+}
+
+// GetRequest returns a copy of a file request
+func (s *Storage) GetRequest(id string) (FileRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	request, exists := s.requests[id]
+	if !exists {
+		return FileRequest{}, ErrRequestNotFound
+	}
+	if !time.Now().Before(request.ExpiresAt) {
+		return FileRequest{}, ErrRequestExpired
+	}
+	return *request, nil
+}
+
+// ReserveRequestUpload claims an upload slot on an open request. Callers
+// must call CancelRequestUpload if the upload is not stored after all.
+func (s *Storage) ReserveRequestUpload(id string) (FileRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, exists := s.requests[id]
+	if !exists {
+		return FileRequest{}, ErrRequestNotFound
+	}
+	now := time.Now()
+	if !now.Before(request.ExpiresAt) {
+		return FileRequest{}, ErrRequestExpired
+	}
+	if !request.Open(now) {
+		return FileRequest{}, ErrRequestFull
+	}
+
+	request.Uploads++
+	return *request, nil
+}
+
+// CancelRequestUpload returns a slot claimed by ReserveRequestUpload
+func (s *Storage) CancelRequestUpload(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if request, exists := s.requests[id]; exists && request.Uploads > 0 {
+		request.Uploads--
+	}
+}
+
+// ListRequests returns copies of the owner's requests, newest first
+func (s *Storage) ListRequests(owner string) []FileRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var requests []FileRequest
+	for _, request := range s.requests {
+		if request.Owner == owner {
+			requests = append(requests, *request)
+		}
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].CreatedAt.After(requests[j].CreatedAt)
+	})
+	return requests
+}
+
+// ListRequestFiles returns copies of the files received by one of the
+// owner's requests, newest first. Received files expire and are shredded
+// like any other share, after which they drop out of the listing. Files
+// outlive a closed or expired request, so they are listed until the last
+// of them is gone.
+func (s *Storage) ListRequestFiles(id, owner string) ([]FileMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	request, exists := s.requests[id]
+	if exists && request.Owner != owner {
+		return nil, ErrRequestNotFound
+	}
+
+	var files []FileMetadata
+	for _, metadata := range s.files {
+		if metadata.RequestID == id && metadata.Owner == owner {
+			files = append(files, *metadata.snapshot())
+		}
+	}
+	if !exists && len(files) == 0 {
+		return nil, ErrRequestNotFound
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedAt.After(files[j].CreatedAt)
+	})
+	return files, nil
+}
+
+// ReceivedFile returns a copy of a file received by one of the owner's
+// requests. Files of other requests or owners get ErrNotFound, like
+// unknown ones.
+func (s *Storage) ReceivedFile(requestID, id, owner string) (*FileMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metadata, exists := s.files[id]
+	if !exists || metadata.RequestID == "" || metadata.RequestID != requestID || metadata.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return metadata.snapshot(), nil
+}
+
+// CloseRequest deletes one of the owner's requests. Files already received
+// stay until they expire.
+func (s *Storage) CloseRequest(id, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, exists := s.requests[id]
+	if !exists || request.Owner != owner {
+		return ErrRequestNotFound
+	}
+
+	delete(s.requests, id)
+	return nil
+}
@@ -1,20 +1,52 @@
 package storage
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hardiksharma/shreadbox/internal/access"
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
 )
 
+// stagingSuffix marks blobs that are still being written to disk
+const stagingSuffix = ".partial"
+
+// defaultGenerator issues UUID tokens until SetTokenGenerator is called
+var defaultGenerator, _ = sharetoken.NewGenerator(sharetoken.FormatUUID, 0)
+
 // Storage represents the file storage service
 type Storage struct {
-	basePath string
-	files    map[string]*FileMetadata
-	mu       sync.RWMutex
+	basePath   string
+	files      map[string]*FileMetadata
+	tokens     map[string]string // share IDs by hash of their public token
+	tokenKey   []byte            // keys token hashes, random per process
+	requests   map[string]*FileRequest
+	blobs      map[string]*sharedBlob // blobs of deduplicated shares, by blob ID
+	totalBytes int64                  // of the blobs on disk, each counted once however many shares use it
+	fileBytes  int64                  // of the files shared, before compression
+	listeners  []Listener
+	mu         sync.RWMutex
+
+	tokenGenerator *sharetoken.Generator
+
+	// Shares ordered by expiry, so each can be destroyed on time
+	expiries      expiryQueue
+	expiryIndex   map[string]*expiryEntry
+	expiryChanged chan struct{}
+
+	// Reconciliation of the storage directory, one run at a time
+	reconcileMu   sync.Mutex
+	lastReconcile *ReconcileReport
 }
 
 // NewStorage creates a new storage service
@@ -24,13 +56,33 @@ func NewStorage(basePath string) (*Storage, error) {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
+	tokenKey := make([]byte, 32)
+	if _, err := rand.Read(tokenKey); err != nil {
+		return nil, fmt.Errorf("failed to generate token key: %w", err)
+	}
+
 	return &Storage{
 		basePath: basePath,
 		files:    make(map[string]*FileMetadata),
+		tokens:   make(map[string]string),
+		tokenKey: tokenKey,
+		requests: make(map[string]*FileRequest),
+		blobs:    make(map[string]*sharedBlob),
+
+		tokenGenerator: defaultGenerator,
+		expiryIndex:    make(map[string]*expiryEntry),
+		expiryChanged:  make(chan struct{}, 1),
 	}, nil
 }
 
-// SaveFile saves an encrypted file and its metadata
+// SaveFile saves an encrypted file and its metadata. Shares with a BlobID
+// are deduplicated: they reuse the blob already stored under that ID, if
+// any, and data is only written for the first of them. Shares get a newly
+// generated token unless one is set already, which fails with
+// ErrTokenTaken if another share uses it. The token is left in
+// metadata.Token for the caller; storage keeps a copy of metadata without
+// it, so from then on shares can only be found by presenting the token.
+// The same goes for the management token every share is given.
 func (s *Storage) SaveFile(data []byte, metadata *FileMetadata) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -39,108 +91,432 @@ func (s *Storage) SaveFile(data []byte, metadata *FileMetadata) error {
 	if metadata.ID == "" {
 		metadata.ID = uuid.New().String()
 	}
+	if err := s.assignToken(metadata); err != nil {
+		return err
+	}
+	if err := s.assignManageToken(metadata); err != nil {
+		return err
+	}
+	metadata.CreatedAt = time.Now()
+
+	// Deduplicated shares of a file stored before only take a reference
+	if metadata.BlobID == "" || !s.attachBlob(metadata) {
+		if err := s.writeBlob(data, metadata); err != nil {
+			return err
+		}
+	}
+	if len(metadata.Preview) > 0 {
+		if err := s.writePreview(metadata); err != nil {
+			s.deleteBlob(metadata)
+			return err
+		}
+	}
+
+	// Store metadata
+	stored := *metadata
+	stored.Token = ""
+	stored.ManageToken = ""
+	stored.Preview = nil
+	s.files[metadata.ID] = &stored
+	s.tokens[metadata.TokenHash] = metadata.ID
+	s.fileBytes += metadata.FileSize
+	s.scheduleExpiry(&stored)
+	return nil
+}
 
+// writeBlob stores a new blob for a share, the caller must hold the lock
+func (s *Storage) writeBlob(data []byte, metadata *FileMetadata) error {
 	// Set file path
 	metadata.FilePath = filepath.Join(s.basePath, metadata.ID)
-	metadata.CreatedAt = time.Now()
+	if metadata.BlobID != "" {
+		metadata.FilePath = s.blobPath(metadata.BlobID)
+	}
+	metadata.StoredSize = int64(len(data))
 
-	// Save encrypted file
-	if err := os.WriteFile(metadata.FilePath, data, 0644); err != nil {
+	if err := writeStaged(metadata.FilePath, data); err != nil {
 		return fmt.Errorf("failed to save file: %w", err)
 	}
+	s.totalBytes += metadata.StoredSize
 
-	// Store metadata
-	s.files[metadata.ID] = metadata
+	if metadata.BlobID != "" {
+		s.blobs[metadata.BlobID] = &sharedBlob{path: metadata.FilePath, size: metadata.StoredSize, refs: 1}
+	}
+	return nil
+}
+
+// writeStaged writes data to a staging path first and renames it into
+// place, so an interrupted write never leaves a truncated file under path
+func writeStaged(path string, data []byte) error {
+	stagingPath := path + stagingSuffix
+	if err := os.WriteFile(stagingPath, data, 0644); err != nil {
+		os.Remove(stagingPath)
+		return err
+	}
+	if err := os.Rename(stagingPath, path); err != nil {
+		os.Remove(stagingPath)
+		return err
+	}
 	return nil
 }
 
-// GetFile retrieves a file's metadata and decrements the download counter
-func (s *Storage) GetFile(id string) (*FileMetadata, error) {
+// GetFile counts a download of a file, returning a copy of its metadata
+// and its encrypted content. The content is read while the lock is held,
+// so the last download can shred the file right away and no concurrent
+// removal can shred it mid-read.
+func (s *Storage) GetFile(id string) (*FileMetadata, []byte, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	metadata, exists := s.files[id]
 	if !exists {
-		return nil, fmt.Errorf("file not found")
+		return nil, nil, ErrNotFound
 	}
 
 	// Check if file has expired
 	if time.Now().After(metadata.ExpiresAt) {
-		s.deleteFile(id)
-		return nil, fmt.Errorf("file has expired")
+		s.deleteFile(id, EventExpired)
+		return nil, nil, ErrExpired
+	}
+
+	// Only scanned files may be downloaded
+	switch metadata.ScanStatus {
+	case ScanPending:
+		return nil, nil, ErrScanPending
+	case ScanInfected, ScanFailed:
+		return nil, nil, ErrInfected
 	}
 
 	// Check if downloads are exhausted
 	if metadata.DownloadsLeft <= 0 {
-		s.deleteFile(id)
-		return nil, fmt.Errorf("download limit reached")
+		s.deleteFile(id, EventExhausted)
+		return nil, nil, ErrDownloadLimit
+	}
+
+	// A download is only counted once the content is in hand
+	data, err := os.ReadFile(metadata.FilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	// Decrement download counter
 	metadata.DownloadsLeft--
+	metadata.DownloadCount++
+	s.emit(EventDownloaded, metadata)
+	downloaded := metadata.snapshot()
 
-	// If no downloads left, schedule deletion
+	// The last download destroys the file; if shredding fails the share
+	// stays exhausted and the next attempt or expiry retries it
 	if metadata.DownloadsLeft == 0 {
-		go func() {
-			s.mu.Lock()
-			defer s.mu.Unlock()
-			s.deleteFile(id)
-		}()
+		s.deleteFile(id, EventExhausted)
 	}
 
-	return metadata, nil
+	return downloaded, data, nil
 }
 
-// ReadFile reads the encrypted file content
-func (s *Storage) ReadFile(metadata *FileMetadata) ([]byte, error) {
-	return os.ReadFile(metadata.FilePath)
+// AuthorizeAccess checks a download attempt from ip against the share's
+// access policy. Refusals are recorded for the owner; allowed addresses
+// count towards the policy's distinct client limit.
+func (s *Storage) AuthorizeAccess(id string, ip netip.Addr) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, exists := s.files[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if metadata.Access == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if err := metadata.Access.Check(ip, now, metadata.AccessClients); err != nil {
+		metadata.AccessDenials = access.RecordDenial(metadata.AccessDenials, access.Denial{
+			At:     now,
+			IP:     ip.Unmap().String(),
+			Reason: err.Error(),
+		})
+		return err
+	}
+
+	if address := ip.Unmap().String(); !slices.Contains(metadata.AccessClients, address) {
+		metadata.AccessClients = append(metadata.AccessClients, address)
+	}
+	return nil
 }
 
-// deleteFile removes a file and its metadata
-func (s *Storage) deleteFile(id string) error {
+// deleteFile removes a file and its metadata, reporting the removal as reason
+func (s *Storage) deleteFile(id string, reason EventType) error {
 	metadata, exists := s.files[id]
 	if !exists {
 		return nil
 	}
 
-	// Remove file from disk
-	if err := os.Remove(metadata.FilePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete file: %w", err)
+	// Remove file from disk, shared blobs once no other share needs them
+	if err := s.deleteBlob(metadata); err != nil {
+		return err
+	}
+	if err := s.shredPreview(metadata); err != nil {
+		return err
 	}
 
 	// Remove metadata from memory
 	delete(s.files, id)
+	delete(s.tokens, metadata.TokenHash)
+	s.fileBytes -= metadata.FileSize
+	s.unscheduleExpiry(id)
+
+	s.emit(reason, metadata)
 	return nil
 }
 
-// CleanupExpired removes expired files
-func (s *Storage) CleanupExpired() error {
+// deleteBlob removes a share's blob from disk, releasing it if shared. The
+// caller must hold the lock.
+func (s *Storage) deleteBlob(metadata *FileMetadata) error {
+	if metadata.BlobID != "" {
+		return s.releaseBlob(metadata)
+	}
+	if metadata.FilePath != "" {
+		if err := shredFile(metadata.FilePath); err != nil {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+		metadata.FilePath = ""
+		s.totalBytes -= metadata.StoredSize
+	}
+	return nil
+}
+
+// Revoke destroys a share on behalf of its owner
+func (s *Storage) Revoke(id, owner string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	now := time.Now()
-	var lastErr error
+	metadata, exists := s.files[id]
+	if !exists || metadata.Owner != owner {
+		return ErrNotFound
+	}
+
+	return s.deleteFile(id, EventRevoked)
+}
+
+// SetScanResult records the malware scan verdict for a share. Infected or
+// unscannable files are shredded immediately, previews included; their
+// metadata stays until expiry so the status endpoint can report what
+// happened.
+func (s *Storage) SetScanResult(id, status, signature string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, exists := s.files[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if status == ScanFailed || status == ScanInfected {
+		if err := s.shredPreview(metadata); err != nil {
+			return err
+		}
+	}
+
+	// Every share of an infected file is condemned with it, while a failed
+	// scan only costs this share its content
+	switch {
+	case metadata.BlobID != "" && status == ScanInfected:
+		return s.condemnBlob(metadata.BlobID, status, signature)
+	case metadata.BlobID != "" && status == ScanFailed:
+		metadata.ScanStatus = status
+		return s.detachBlob(metadata)
+	}
+
+	metadata.ScanStatus = status
+	metadata.ScanSignature = signature
+
+	if status == ScanInfected || status == ScanFailed {
+		if err := s.deleteBlob(metadata); err != nil {
+			return fmt.Errorf("failed to shred file: %w", err)
+		}
+	}
+	return nil
+}
+
+// shredFile overwrites a file with random data before removing it, so its
+// ciphertext cannot be recovered from the disk afterwards
+func shredFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err == nil {
+		_, err = io.CopyN(file, rand.Reader, info.Size())
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// CleanupExpired sweeps all shares for expired or exhausted ones. Shares
+// are normally destroyed on time by ExpireDue; the sweep is a safety net.
+// Candidates are collected under a read lock and removed one at a time, so
+// a large store never blocks downloads for the whole sweep.
+func (s *Storage) CleanupExpired() (CleanupStats, error) {
+	start := time.Now()
+	now := start
 
+	type candidate struct {
+		id     string
+		reason EventType
+	}
+	var candidates []candidate
+	var expiredRequests []string
+
+	s.mu.RLock()
+	stats := CleanupStats{Scanned: len(s.files)}
 	for id, metadata := range s.files {
-		if now.After(metadata.ExpiresAt) || metadata.DownloadsLeft <= 0 {
-			if err := s.deleteFile(id); err != nil {
-				lastErr = err
+		switch {
+		case now.After(metadata.ExpiresAt):
+			candidates = append(candidates, candidate{id, EventExpired})
+		case metadata.DownloadsLeft <= 0:
+			candidates = append(candidates, candidate{id, EventExhausted})
+		}
+	}
+	for id, request := range s.requests {
+		if now.After(request.ExpiresAt) {
+			expiredRequests = append(expiredRequests, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	var errs []error
+	for _, c := range candidates {
+		size, removed, err := s.removeIfStale(c.id, c.reason, now)
+		switch {
+		case err != nil:
+			stats.Failed++
+			errs = append(errs, err)
+		case !removed:
+		case c.reason == EventExpired:
+			stats.Expired++
+			stats.BytesFreed += size
+		default:
+			stats.Exhausted++
+			stats.BytesFreed += size
+		}
+	}
+
+	// Expired file requests go too, the files they received keep their
+	// own expiry
+	if len(expiredRequests) > 0 {
+		s.mu.Lock()
+		for _, id := range expiredRequests {
+			if request, exists := s.requests[id]; exists && now.After(request.ExpiresAt) {
+				delete(s.requests, id)
+				stats.Requests++
 			}
 		}
+		s.mu.Unlock()
 	}
 
-	return lastErr
+	stats.Duration = time.Since(start)
+	return stats, errors.Join(errs...)
 }
 
-// GetFileMetadata retrieves file metadata without modifying the download counter
+// removeIfStale deletes a sweep candidate, re-checking it under the write
+// lock as it may have changed since it was collected
+func (s *Storage) removeIfStale(id string, reason EventType, now time.Time) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, exists := s.files[id]
+	if !exists {
+		return 0, false, nil
+	}
+	if reason == EventExpired && !now.After(metadata.ExpiresAt) {
+		return 0, false, nil
+	}
+	if reason == EventExhausted && metadata.DownloadsLeft > 0 {
+		return 0, false, nil
+	}
+
+	size := metadata.StoredSize
+	if err := s.deleteFile(id, reason); err != nil {
+		return 0, false, err
+	}
+	return size, true, nil
+}
+
+// GetFileMetadata returns a copy of a file's metadata without modifying the
+// download counter
 func (s *Storage) GetFileMetadata(id string) (*FileMetadata, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	metadata, exists := s.files[id]
 	if !exists {
-		return nil, fmt.Errorf("file not found")
+		return nil, ErrNotFound
+	}
+
+	return metadata.snapshot(), nil
+}
+
+// ListByOwner returns copies of the active shares charged to owner, newest first
+func (s *Storage) ListByOwner(owner string) []FileMetadata {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var shares []FileMetadata
+	for _, metadata := range s.files {
+		if metadata.Owner == owner && now.Before(metadata.ExpiresAt) && metadata.DownloadsLeft > 0 {
+			shares = append(shares, *metadata.snapshot())
+		}
 	}
 
-	return metadata, nil
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].CreatedAt.After(shares[j].CreatedAt)
+	})
+	return shares
+}
+
+// Stats returns aggregate information about stored files
+func (s *Storage) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Stats{
+		ActiveShares:  len(s.files),
+		TotalBytes:    s.totalBytes,
+		OriginalBytes: s.fileBytes,
+	}
+}
+
+// RemoveStaging removes incomplete staging files left behind by interrupted writes
+func (s *Storage) RemoveStaging() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches, err := filepath.Glob(filepath.Join(s.basePath, "*"+stagingSuffix))
+	if err != nil {
+		return fmt.Errorf("failed to list staging files: %w", err)
+	}
+
+	var lastErr error
+	for _, path := range matches {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			lastErr = fmt.Errorf("failed to remove staging file: %w", err)
+		}
+	}
+
+	return lastErr
 }
@@ -1,11 +1,18 @@
 package storage
 
 import (
+	"net/netip"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/hardiksharma/shreadbox/internal/access"
+	"github.com/hardiksharma/shreadbox/internal/domain"
+	"github.com/hardiksharma/shreadbox/internal/encryption"
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -83,14 +90,16 @@ func TestStorage_GetFile(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test getting file
-	retrieved, err := storage.GetFile(metadata.ID)
+	retrieved, data, err := storage.GetFile(metadata.ID)
 	assert.NoError(t, err)
 	assert.NotNil(t, retrieved)
+	assert.Equal(t, testData, data)
 	assert.Equal(t, metadata.FileName, retrieved.FileName)
 	assert.Equal(t, 0, retrieved.DownloadsLeft) // Should be decremented
+	assert.NoFileExists(t, metadata.FilePath, "the last download shreds the file")
 
 	// Test getting non-existent file
-	retrieved, err = storage.GetFile("non-existent")
+	retrieved, _, err = storage.GetFile("non-existent")
 	assert.Error(t, err)
 	assert.Nil(t, retrieved)
 }
@@ -139,7 +148,11 @@ func TestStorage_CleanupExpired(t *testing.T) {
 	}
 
 	// Run cleanup
-	storage.CleanupExpired()
+	stats, err := storage.CleanupExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Scanned)
+	assert.Equal(t, 1, stats.Expired)
+	assert.Equal(t, int64(4), stats.BytesFreed)
 
 	// Verify results
 	for _, tc := range testCases {
@@ -151,3 +164,686 @@ func TestStorage_CleanupExpired(t *testing.T) {
 		}
 	}
 }
+
+func TestStorage_RemoveStaging(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	// Save a complete file and simulate an interrupted write
+	metadata := &FileMetadata{
+		FileName:      "test.txt",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		DownloadsLeft: 1,
+	}
+	err = storage.SaveFile([]byte("test"), metadata)
+	assert.NoError(t, err)
+	assert.NoFileExists(t, metadata.FilePath+stagingSuffix)
+
+	partial := filepath.Join(tempDir, "interrupted"+stagingSuffix)
+	assert.NoError(t, os.WriteFile(partial, []byte("half"), 0644))
+
+	// Only the staging file should be removed
+	assert.NoError(t, storage.RemoveStaging())
+	assert.NoFileExists(t, partial)
+	assert.FileExists(t, metadata.FilePath)
+}
+
+func TestStorage_Events(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	var events []Event
+	storage.Subscribe(func(event Event) {
+		events = append(events, event)
+	})
+
+	// Save an expired, an exhausted and a valid file
+	expired := &FileMetadata{
+		FileName:  "expired.txt",
+		ExpiresAt: time.Now().Add(-time.Hour),
+		Owner:     "ip:192.0.2.1",
+	}
+	exhausted := &FileMetadata{
+		FileName:  "exhausted.txt",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	valid := &FileMetadata{
+		FileName:      "valid.txt",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		DownloadsLeft: 2,
+		Owner:         "key:a",
+	}
+	assert.NoError(t, storage.SaveFile([]byte("expired"), expired))
+	assert.NoError(t, storage.SaveFile([]byte("gone"), exhausted))
+	assert.NoError(t, storage.SaveFile([]byte("valid!"), valid))
+	assert.Equal(t, Stats{ActiveShares: 3, TotalBytes: 17}, storage.Stats())
+
+	// Cleanup removes the expired and exhausted files with their reasons
+	stats, err := storage.CleanupExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Expired)
+	assert.Equal(t, 1, stats.Exhausted)
+	assert.Len(t, events, 2)
+	reasons := map[string]EventType{}
+	for _, event := range events {
+		reasons[event.File.FileName] = event.Type
+	}
+	assert.Equal(t, EventExpired, reasons["expired.txt"])
+	assert.Equal(t, EventExhausted, reasons["exhausted.txt"])
+	assert.Equal(t, Stats{ActiveShares: 1, TotalBytes: 6}, storage.Stats())
+
+	// Downloads are reported with the remaining count
+	events = nil
+	_, _, err = storage.GetFile(valid.ID)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventDownloaded, events[0].Type)
+	assert.Equal(t, 1, events[0].File.DownloadsLeft)
+	assert.Equal(t, 1, events[0].File.DownloadCount)
+
+	// Only the owner can revoke
+	events = nil
+	assert.ErrorIs(t, storage.Revoke(valid.ID, "key:b"), ErrNotFound)
+	assert.NoError(t, storage.Revoke(valid.ID, "key:a"))
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventRevoked, events[0].Type)
+	assert.True(t, events[0].Type.Removed())
+	assert.Equal(t, Stats{}, storage.Stats())
+}
+
+func TestStorage_ListByOwner(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		owner     string
+		expiresAt time.Time
+	}{
+		{"mine.txt", "key:a", time.Now().Add(time.Hour)},
+		{"expired.txt", "key:a", time.Now().Add(-time.Hour)},
+		{"theirs.txt", "key:b", time.Now().Add(time.Hour)},
+	}
+
+	for _, tc := range testCases {
+		err := storage.SaveFile([]byte("test"), &FileMetadata{
+			FileName:      tc.name,
+			Owner:         tc.owner,
+			ExpiresAt:     tc.expiresAt,
+			DownloadsLeft: 1,
+		})
+		assert.NoError(t, err)
+	}
+
+	shares := storage.ListByOwner("key:a")
+	assert.Len(t, shares, 1)
+	assert.Equal(t, "mine.txt", shares[0].FileName)
+	assert.Empty(t, storage.ListByOwner("key:c"))
+}
+
+func TestStorage_GetFileErrors(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	expired := &FileMetadata{ExpiresAt: time.Now().Add(-time.Hour), DownloadsLeft: 1}
+	exhausted := &FileMetadata{ExpiresAt: time.Now().Add(time.Hour), DownloadsLeft: 0}
+	assert.NoError(t, storage.SaveFile([]byte("test"), expired))
+	assert.NoError(t, storage.SaveFile([]byte("test"), exhausted))
+
+	_, _, err = storage.GetFile("non-existent")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	_, _, err = storage.GetFile(expired.ID)
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.ErrorIs(t, err, domain.ErrExpired)
+
+	_, _, err = storage.GetFile(exhausted.ID)
+	assert.ErrorIs(t, err, ErrDownloadLimit)
+	assert.ErrorIs(t, err, domain.ErrExhausted)
+
+	// Both were destroyed on access
+	_, _, err = storage.GetFile(expired.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStorage_SetScanResult(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	newPending := func() *FileMetadata {
+		metadata := &FileMetadata{
+			ExpiresAt:     time.Now().Add(time.Hour),
+			DownloadsLeft: 1,
+			ScanStatus:    ScanPending,
+		}
+		assert.NoError(t, storage.SaveFile([]byte("test"), metadata))
+		return metadata
+	}
+
+	// Pending files cannot be downloaded
+	clean := newPending()
+	_, _, err = storage.GetFile(clean.ID)
+	assert.ErrorIs(t, err, ErrScanPending)
+
+	// Clean files become available
+	assert.NoError(t, storage.SetScanResult(clean.ID, ScanClean, ""))
+	_, _, err = storage.GetFile(clean.ID)
+	assert.NoError(t, err)
+
+	// Infected files are shredded but their verdict stays visible
+	infected := newPending()
+	assert.NoError(t, storage.SetScanResult(infected.ID, ScanInfected, "Eicar-Test-Signature"))
+	assert.NoFileExists(t, infected.FilePath)
+
+	_, _, err = storage.GetFile(infected.ID)
+	assert.ErrorIs(t, err, ErrInfected)
+
+	status, err := storage.GetFileMetadata(infected.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ScanInfected, status.ScanStatus)
+	assert.Equal(t, "Eicar-Test-Signature", status.ScanSignature)
+
+	assert.ErrorIs(t, storage.SetScanResult("non-existent", ScanClean, ""), ErrNotFound)
+}
+
+func TestStorage_FileRequests(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	request := &FileRequest{
+		Title:      "Send us your logs",
+		Owner:      "key:a",
+		MaxUploads: 1,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	storage.CreateRequest(request)
+	assert.NotEmpty(t, request.ID)
+
+	// Uploads claim a slot, cancelled uploads give it back
+	_, err = storage.ReserveRequestUpload(request.ID)
+	assert.NoError(t, err)
+	_, err = storage.ReserveRequestUpload(request.ID)
+	assert.ErrorIs(t, err, ErrRequestFull)
+	storage.CancelRequestUpload(request.ID)
+	reserved, err := storage.ReserveRequestUpload(request.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reserved.Uploads)
+
+	// Received files are listed for the owner only
+	received := &FileMetadata{
+		FileName:      "logs.tar",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		DownloadsLeft: 1,
+		Owner:         "key:a",
+		RequestID:     request.ID,
+	}
+	assert.NoError(t, storage.SaveFile([]byte("logs"), received))
+	assert.NoError(t, storage.SaveFile([]byte("other"), &FileMetadata{
+		FileName:      "other.txt",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		DownloadsLeft: 1,
+		Owner:         "key:a",
+	}))
+
+	files, err := storage.ListRequestFiles(request.ID, "key:a")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "logs.tar", files[0].FileName)
+	_, err = storage.ListRequestFiles(request.ID, "key:b")
+	assert.ErrorIs(t, err, ErrRequestNotFound)
+
+	// Received files are fetched by ID only through their request, by its owner
+	file, err := storage.ReceivedFile(request.ID, received.ID, "key:a")
+	assert.NoError(t, err)
+	assert.Equal(t, "logs.tar", file.FileName)
+	_, err = storage.ReceivedFile(request.ID, received.ID, "key:b")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = storage.ReceivedFile("other", received.ID, "key:a")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Len(t, storage.ListRequests("key:a"), 1)
+	assert.Empty(t, storage.ListRequests("key:b"))
+
+	// Closing keeps received files
+	assert.ErrorIs(t, storage.CloseRequest(request.ID, "key:b"), ErrRequestNotFound)
+	assert.NoError(t, storage.CloseRequest(request.ID, "key:a"))
+	_, err = storage.GetRequest(request.ID)
+	assert.ErrorIs(t, err, ErrRequestNotFound)
+	_, err = storage.GetFileMetadata(received.ID)
+	assert.NoError(t, err)
+
+	// and lists them until the last one is gone
+	files, err = storage.ListRequestFiles(request.ID, "key:a")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, err = storage.ListRequestFiles(request.ID, "key:b")
+	assert.ErrorIs(t, err, ErrRequestNotFound)
+	assert.NoError(t, storage.Revoke(received.ID, "key:a"))
+	_, err = storage.ListRequestFiles(request.ID, "key:a")
+	assert.ErrorIs(t, err, ErrRequestNotFound)
+
+	// Cleanup drops expired requests
+	expired := &FileRequest{Title: "old", Owner: "key:a", ExpiresAt: time.Now().Add(-time.Minute)}
+	storage.CreateRequest(expired)
+	_, err = storage.GetRequest(expired.ID)
+	assert.ErrorIs(t, err, ErrRequestExpired)
+	stats, err := storage.CleanupExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Requests)
+	assert.Empty(t, storage.ListRequests("key:a"))
+}
+
+func TestStorage_AuthorizeAccess(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	policy, err := access.Parse(access.Rules{AllowedIPs: "10.0.0.0/8", MaxClients: "1"})
+	assert.NoError(t, err)
+
+	metadata := &FileMetadata{
+		FileName:      "guarded.txt",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		DownloadsLeft: 3,
+		Access:        policy,
+	}
+	assert.NoError(t, storage.SaveFile([]byte("guarded"), metadata))
+
+	// Refusals are recorded and do not consume downloads
+	assert.ErrorIs(t, storage.AuthorizeAccess(metadata.ID, netip.MustParseAddr("192.0.2.1")), access.ErrIPNotAllowed)
+	assert.NoError(t, storage.AuthorizeAccess(metadata.ID, netip.MustParseAddr("10.0.0.1")))
+	assert.NoError(t, storage.AuthorizeAccess(metadata.ID, netip.MustParseAddr("10.0.0.1")))
+	assert.ErrorIs(t, storage.AuthorizeAccess(metadata.ID, netip.MustParseAddr("10.0.0.2")), access.ErrTooManyClients)
+
+	stored, err := storage.GetFileMetadata(metadata.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stored.DownloadsLeft)
+	assert.Equal(t, []string{"10.0.0.1"}, stored.AccessClients)
+	assert.Len(t, stored.AccessDenials, 2)
+	assert.Equal(t, "192.0.2.1", stored.AccessDenials[0].IP)
+
+	assert.ErrorIs(t, storage.AuthorizeAccess("missing", netip.MustParseAddr("10.0.0.1")), ErrNotFound)
+}
+
+func TestStorage_ExpireDue(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	_, ok := storage.NextExpiry()
+	assert.False(t, ok)
+
+	now := time.Now()
+	first := &FileMetadata{ExpiresAt: now.Add(time.Minute), DownloadsLeft: 1}
+	second := &FileMetadata{ExpiresAt: now.Add(2 * time.Minute), DownloadsLeft: 1}
+	revoked := &FileMetadata{ExpiresAt: now.Add(30 * time.Second), DownloadsLeft: 1, Owner: "key:a"}
+	assert.NoError(t, storage.SaveFile([]byte("second"), second))
+	assert.NoError(t, storage.SaveFile([]byte("first"), first))
+
+	// Earlier deadlines wake the scheduler
+	select {
+	case <-storage.ExpiryChanged():
+	default:
+		t.Fatal("expected an expiry change signal")
+	}
+	assert.NoError(t, storage.SaveFile([]byte("revoked"), revoked))
+	next, ok := storage.NextExpiry()
+	assert.True(t, ok)
+	assert.Equal(t, revoked.ExpiresAt, next)
+
+	// Removed shares leave the schedule
+	assert.NoError(t, storage.Revoke(revoked.ID, "key:a"))
+	next, _ = storage.NextExpiry()
+	assert.Equal(t, first.ExpiresAt, next)
+
+	// Only shares that are due are destroyed
+	stats, err := storage.ExpireDue(now.Add(90 * time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Expired)
+	assert.Equal(t, int64(5), stats.BytesFreed)
+	_, err = storage.GetFileMetadata(first.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = storage.GetFileMetadata(second.ID)
+	assert.NoError(t, err)
+
+	next, _ = storage.NextExpiry()
+	assert.Equal(t, second.ExpiresAt, next)
+}
+
+func TestStorage_Reconcile(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	var events []Event
+	storage.Subscribe(func(event Event) {
+		events = append(events, event)
+	})
+
+	blob := encryptedBlob(t)
+	expiry := time.Now().Add(time.Hour)
+	healthy := &FileMetadata{ExpiresAt: expiry, DownloadsLeft: 1}
+	missing := &FileMetadata{ExpiresAt: expiry, DownloadsLeft: 1}
+	truncated := &FileMetadata{ExpiresAt: expiry, DownloadsLeft: 1}
+	short := &FileMetadata{ExpiresAt: expiry, DownloadsLeft: 1}
+	infected := &FileMetadata{ExpiresAt: expiry, DownloadsLeft: 1}
+	for _, metadata := range []*FileMetadata{healthy, missing, truncated, infected} {
+		assert.NoError(t, storage.SaveFile(blob, metadata))
+	}
+	assert.NoError(t, storage.SaveFile([]byte("short"), short))
+	assert.NoError(t, storage.SetScanResult(infected.ID, ScanInfected, "Eicar-Test-Signature"))
+
+	// Drift left behind by crashes and failed removals
+	orphan := uuid.New().String()
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, orphan), blob, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, uuid.New().String()+stagingSuffix), blob, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "notes.txt"), blob, 0644))
+	assert.NoError(t, os.Remove(missing.FilePath))
+	assert.NoError(t, os.Truncate(truncated.FilePath, 32))
+
+	_, ok := storage.LastReconcile()
+	assert.False(t, ok)
+
+	report, err := storage.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Orphans)
+	assert.Equal(t, 1, report.Staging)
+	assert.Equal(t, 1, report.MissingBlobs)
+	assert.Equal(t, 1, report.SizeMismatches)
+	assert.Equal(t, 1, report.Unreadable)
+	assert.Equal(t, 5, report.Problems())
+	assert.Len(t, report.Issues, 5)
+
+	// Orphans are shredded, files that are not blobs are left alone
+	assert.NoFileExists(t, filepath.Join(tempDir, orphan))
+	assert.FileExists(t, filepath.Join(tempDir, "notes.txt"))
+
+	// Damaged blobs are kept aside and their shares removed
+	assert.FileExists(t, filepath.Join(tempDir, quarantineDir, truncated.ID))
+	assert.FileExists(t, filepath.Join(tempDir, quarantineDir, short.ID))
+	for _, metadata := range []*FileMetadata{missing, truncated, short} {
+		_, err := storage.GetFileMetadata(metadata.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Len(t, events, 3)
+	for _, event := range events {
+		assert.Equal(t, EventCorrupted, event.Type)
+	}
+
+	// Healthy shares and scan tombstones stay
+	_, err = storage.GetFileMetadata(healthy.ID)
+	assert.NoError(t, err)
+	_, err = storage.GetFileMetadata(infected.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{ActiveShares: 2, TotalBytes: 64}, storage.Stats())
+
+	last, ok := storage.LastReconcile()
+	assert.True(t, ok)
+	assert.Equal(t, report.StartedAt, last.StartedAt)
+
+	// A second run finds nothing to do
+	report, err = storage.Reconcile()
+	assert.NoError(t, err)
+	assert.Zero(t, report.Problems())
+}
+
+func TestStorage_SharedBlobs(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	blobID := strings.Repeat("ab", 32)
+	expiry := time.Now().Add(time.Hour)
+	first := &FileMetadata{BlobID: blobID, ExpiresAt: expiry, DownloadsLeft: 1}
+	second := &FileMetadata{BlobID: blobID, ExpiresAt: expiry, DownloadsLeft: 2}
+	assert.NoError(t, storage.SaveFile(encryptedBlob(t), first))
+	assert.NoError(t, storage.SaveFile(encryptedBlob(t), second))
+
+	// Both shares point at one blob, each charged in full but stored once
+	assert.Equal(t, first.FilePath, second.FilePath)
+	assert.Equal(t, filepath.Join(tempDir, "blob-"+blobID), first.FilePath)
+	assert.Equal(t, int64(64), second.StoredSize)
+	assert.Equal(t, Stats{ActiveShares: 2, TotalBytes: 64}, storage.Stats())
+
+	// Reconcile leaves blobs in use alone
+	report, err := storage.Reconcile()
+	assert.NoError(t, err)
+	assert.Zero(t, report.Problems())
+
+	// The blob outlives all but the last share
+	assert.NoError(t, storage.Revoke(first.ID, ""))
+	assert.FileExists(t, second.FilePath)
+	assert.Equal(t, int64(64), storage.Stats().TotalBytes)
+	assert.NoError(t, storage.Revoke(second.ID, ""))
+	assert.NoFileExists(t, second.FilePath)
+	assert.Empty(t, storage.blobs)
+	assert.Zero(t, storage.Stats().TotalBytes)
+
+	// An infected verdict condemns every share of the file
+	third := &FileMetadata{BlobID: blobID, ExpiresAt: expiry, DownloadsLeft: 1}
+	fourth := &FileMetadata{BlobID: blobID, ExpiresAt: expiry, DownloadsLeft: 1}
+	assert.NoError(t, storage.SaveFile(encryptedBlob(t), third))
+	assert.NoError(t, storage.SaveFile(encryptedBlob(t), fourth))
+	assert.NoError(t, storage.SetScanResult(third.ID, ScanInfected, "Eicar-Test-Signature"))
+	assert.NoFileExists(t, filepath.Join(tempDir, "blob-"+blobID))
+	_, _, err = storage.GetFile(fourth.ID)
+	assert.ErrorIs(t, err, ErrInfected)
+	assert.Zero(t, storage.Stats().TotalBytes)
+
+	// Later uploads of the same file start a fresh blob
+	fifth := &FileMetadata{BlobID: blobID, ExpiresAt: expiry, DownloadsLeft: 1}
+	assert.NoError(t, storage.SaveFile(encryptedBlob(t), fifth))
+	assert.FileExists(t, fifth.FilePath)
+	assert.NoError(t, storage.Revoke(third.ID, ""))
+	assert.NoError(t, storage.Revoke(fourth.ID, ""))
+	assert.FileExists(t, fifth.FilePath)
+
+	// Shared blobs nobody references are orphans
+	orphan := filepath.Join(tempDir, "blob-"+strings.Repeat("cd", 32))
+	assert.NoError(t, os.WriteFile(orphan, encryptedBlob(t), 0644))
+	report, err = storage.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Orphans)
+	assert.NoFileExists(t, orphan)
+}
+
+// encryptedBlob returns a 64 byte blob that passes reconciliation
+func encryptedBlob(t *testing.T) []byte {
+	blob, err := encryption.Encrypt(make([]byte, 64-encryption.Overhead), make([]byte, encryption.KeySize))
+	assert.NoError(t, err)
+	return blob
+}
+
+func TestStorage_Tokens(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+	generator, err := sharetoken.NewGenerator(sharetoken.FormatWords, 3)
+	assert.NoError(t, err)
+	storage.SetTokenGenerator(generator)
+
+	newShare := func(token string) *FileMetadata {
+		return &FileMetadata{Token: token, ExpiresAt: time.Now().Add(time.Hour), DownloadsLeft: 1}
+	}
+
+	// Generated tokens link to the share but are not its ID or file name
+	share := newShare("")
+	assert.NoError(t, storage.SaveFile([]byte("data"), share))
+	assert.Regexp(t, `^\d+(-[a-z]+){3}$`, share.Token)
+	assert.NotEqual(t, share.ID, share.Token)
+	assert.NotContains(t, share.FilePath, share.Token)
+
+	// Only the caller sees the token, storage keeps a keyed hash of it
+	stored, err := storage.GetFileMetadata(share.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, stored.Token)
+	assert.NotEmpty(t, stored.TokenHash)
+	assert.NotContains(t, stored.TokenHash, share.Token)
+
+	id, err := storage.Lookup(share.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, share.ID, id)
+
+	// Codes read aloud or typed by people are still found
+	id, err = storage.Lookup(strings.ToUpper(strings.ReplaceAll(share.Token, "-", " ")))
+	assert.NoError(t, err)
+	assert.Equal(t, share.ID, id)
+
+	// Aliases are kept as given and cannot be claimed twice
+	alias := newShare("q3-report")
+	assert.NoError(t, storage.SaveFile([]byte("data"), alias))
+	assert.Equal(t, "q3-report", alias.Token)
+	assert.ErrorIs(t, storage.SaveFile([]byte("data"), newShare("q3-report")), ErrTokenTaken)
+
+	// Removing a share frees its token
+	assert.NoError(t, storage.Revoke(alias.ID, ""))
+	_, err = storage.Lookup("q3-report")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, storage.SaveFile([]byte("data"), newShare("q3-report")))
+
+	// IDs are not tokens
+	_, err = storage.Lookup(share.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStorage_Previews(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	blob := encryptedBlob(t)
+	newShare := func(previews int) *FileMetadata {
+		return &FileMetadata{
+			ExpiresAt:     time.Now().Add(time.Hour),
+			DownloadsLeft: 1,
+			PreviewsLeft:  previews,
+			PreviewType:   "image/png",
+			Preview:       []byte("encrypted preview"),
+		}
+	}
+
+	// Previews are stored next to the blob and counted apart from downloads
+	share := newShare(2)
+	assert.NoError(t, storage.SaveFile(blob, share))
+	assert.FileExists(t, share.PreviewPath)
+	stored, err := storage.GetFileMetadata(share.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, stored.Preview, "storage keeps only the path")
+
+	for range 2 {
+		_, data, err := storage.GetPreview(share.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "encrypted preview", string(data))
+	}
+	_, _, err = storage.GetPreview(share.ID)
+	assert.ErrorIs(t, err, ErrPreviewLimit)
+	assert.Equal(t, 1, stored.DownloadsLeft)
+
+	// Shares without a preview say so
+	plain := &FileMetadata{ExpiresAt: time.Now().Add(time.Hour), DownloadsLeft: 1, PreviewsLeft: 1}
+	assert.NoError(t, storage.SaveFile(blob, plain))
+	_, _, err = storage.GetPreview(plain.ID)
+	assert.ErrorIs(t, err, ErrNoPreview)
+
+	// Reconciliation keeps live previews and shreds orphaned ones
+	orphan := filepath.Join(tempDir, uuid.New().String()+previewSuffix)
+	assert.NoError(t, os.WriteFile(orphan, blob, 0644))
+	report, err := storage.Reconcile()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Orphans)
+	assert.FileExists(t, share.PreviewPath)
+	assert.NoFileExists(t, orphan)
+
+	// Previews go with the file, whether scanned away or removed
+	infected := newShare(1)
+	assert.NoError(t, storage.SaveFile(blob, infected))
+	assert.NoError(t, storage.SetScanResult(infected.ID, ScanInfected, "Eicar-Test-Signature"))
+	assert.NoFileExists(t, infected.PreviewPath)
+	_, _, err = storage.GetPreview(infected.ID)
+	assert.ErrorIs(t, err, ErrInfected)
+
+	assert.NoError(t, storage.Revoke(share.ID, ""))
+	assert.NoFileExists(t, share.PreviewPath)
+}
+
+func TestStorage_Manage(t *testing.T) {
+	// Setup
+	tempDir := filepath.Join(os.TempDir(), "shreadbox-test")
+	defer os.RemoveAll(tempDir)
+
+	storage, err := NewStorage(tempDir)
+	assert.NoError(t, err)
+
+	share := &FileMetadata{ExpiresAt: time.Now().Add(time.Hour), DownloadsLeft: 1}
+	assert.NoError(t, storage.SaveFile([]byte("data"), share))
+	assert.NotEmpty(t, share.ManageToken)
+	assert.NotEqual(t, share.Token, share.ManageToken)
+
+	// Only the caller sees the management token, storage keeps a keyed hash of it
+	stored, err := storage.GetFileMetadata(share.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, stored.ManageToken)
+	assert.NotEmpty(t, stored.ManageHash)
+
+	managed, err := storage.Manage(share.ID, share.ManageToken)
+	assert.NoError(t, err)
+	assert.Equal(t, share.ID, managed.ID)
+
+	// Other shares' tokens, download tokens and nothing manage nothing
+	other := &FileMetadata{ExpiresAt: time.Now().Add(time.Hour), DownloadsLeft: 1}
+	assert.NoError(t, storage.SaveFile([]byte("data"), other))
+	for _, token := range []string{other.ManageToken, share.Token, ""} {
+		_, err = storage.Manage(share.ID, token)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, storage.RevokeManaged(share.ID, token), ErrNotFound)
+	}
+	_, err = storage.Lookup(share.ManageToken)
+	assert.ErrorIs(t, err, ErrNotFound, "management tokens are not download tokens")
+
+	assert.NoError(t, storage.RevokeManaged(share.ID, share.ManageToken))
+	_, err = storage.Manage(share.ID, share.ManageToken)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hardiksharma/shreadbox/internal/sharetoken"
+)
+
+// maxTokenAttempts bounds how often a colliding generated token is retried
+const maxTokenAttempts = 8
+
+// SetTokenGenerator sets how tokens are generated for new shares. Shares
+// already stored keep their tokens.
+func (s *Storage) SetTokenGenerator(generator *sharetoken.Generator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenGenerator = generator
+}
+
+// Lookup returns the ID of the share a token links to. Tokens typed by
+// people are matched after normalizing, so word codes and aliases are not
+// case sensitive.
+func (s *Storage) Lookup(token string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id, ok := s.tokens[s.hashToken(token)]; ok {
+		return id, nil
+	}
+	if id, ok := s.tokens[s.hashToken(sharetoken.Normalize(token))]; ok {
+		return id, nil
+	}
+	return "", ErrNotFound
+}
+
+// hashToken returns the key a token is indexed under. The HMAC key lives
+// only in memory, so even short word codes cannot be recovered from hashes.
+func (s *Storage) hashToken(token string) string {
+	mac := hmac.New(sha256.New, s.tokenKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// assignToken gives a new share its token: the alias already set, which
+// must be free, or a newly generated one. Only the token's hash is kept.
+// The caller must hold the lock.
+func (s *Storage) assignToken(metadata *FileMetadata) error {
+	if metadata.Token != "" {
+		hash := s.hashToken(metadata.Token)
+		if _, taken := s.tokens[hash]; taken {
+			return ErrTokenTaken
+		}
+		metadata.TokenHash = hash
+		return nil
+	}
+
+	for range maxTokenAttempts {
+		token, err := s.tokenGenerator.Generate()
+		if err != nil {
+			return err
+		}
+		hash := s.hashToken(token)
+		if _, taken := s.tokens[hash]; !taken {
+			metadata.Token, metadata.TokenHash = token, hash
+			return nil
+		}
+	}
+	return fmt.Errorf("no free %s token after %d attempts", s.tokenGenerator.Format(), maxTokenAttempts)
+}
@@ -0,0 +1,349 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/domain"
+	"github.com/hardiksharma/shreadbox/internal/storage"
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "X-ShreadBox-Signature"
+	EventHeader     = "X-ShreadBox-Event"
+)
+
+// ErrInvalidURL is returned for notify URLs that cannot receive webhooks
+var ErrInvalidURL = domain.NewError(domain.ErrInvalidInput, "notify_url must be an absolute http or https URL")
+
+// Event is the JSON payload delivered to webhook endpoints
+type Event struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	ShareID       string    `json:"share_id"`
+	FileName      string    `json:"file_name"`
+	DownloadsLeft int       `json:"downloads_left"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// Options configures a Dispatcher
+type Options struct {
+	GlobalURLs     []string // receive events for every share
+	Secret         string   // signs deliveries to GlobalURLs
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	Timeout        time.Duration
+	QueueSize      int
+	DeadLetterPath string // failed deliveries are appended here as JSON lines
+	AllowPrivate   bool   // allow delivering to loopback and private networks
+}
+
+// delivery is a single event bound for a single endpoint
+type delivery struct {
+	URL      string
+	Secret   string
+	Event    Event
+	Attempts int
+}
+
+// deadLetter is the record written for deliveries that were given up on
+type deadLetter struct {
+	URL      string    `json:"url"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Dispatcher delivers signed webhook events in the background, retrying
+// failed deliveries with exponential backoff
+type Dispatcher struct {
+	opts   Options
+	client *http.Client
+	queue  chan delivery
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	deadLetterMu sync.Mutex
+}
+
+// NewDispatcher creates a new dispatcher, call Start to begin delivering
+func NewDispatcher(opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = rejectPrivateAddress
+	}
+
+	return &Dispatcher{
+		opts: opts,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		queue: make(chan delivery, opts.QueueSize),
+		stop:  make(chan struct{}),
+	}
+}
+
+// Start launches the delivery workers
+func (d *Dispatcher) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+}
+
+// Stop stops the workers, waiting for in-progress deliveries until ctx is
+// done. Deliveries still queued are written to the dead-letter log.
+func (d *Dispatcher) Stop(ctx context.Context) {
+	d.once.Do(func() {
+		close(d.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	for {
+		select {
+		case item := <-d.queue:
+			d.writeDeadLetter(item, errors.New("dispatcher stopped"))
+		default:
+			return
+		}
+	}
+}
+
+// Listener returns a storage listener turning share events into webhooks
+func (d *Dispatcher) Listener() storage.Listener {
+	return func(e storage.Event) {
+		event := Event{
+			ID:            newEventID(),
+			Type:          string(e.Type),
+			ShareID:       e.File.ID,
+			FileName:      e.File.FileName,
+			DownloadsLeft: e.File.DownloadsLeft,
+			ExpiresAt:     e.File.ExpiresAt,
+			OccurredAt:    e.OccurredAt,
+		}
+
+		for _, target := range d.opts.GlobalURLs {
+			d.enqueue(delivery{URL: target, Secret: d.opts.Secret, Event: event})
+		}
+		if e.File.NotifyURL != "" {
+			d.enqueue(delivery{URL: e.File.NotifyURL, Secret: e.File.NotifySecret, Event: event})
+		}
+	}
+}
+
+// enqueue never blocks, as it runs from storage listeners
+func (d *Dispatcher) enqueue(item delivery) {
+	select {
+	case d.queue <- item:
+	default:
+		d.writeDeadLetter(item, errors.New("queue full"))
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case item := <-d.queue:
+			d.process(item)
+		}
+	}
+}
+
+// process delivers an item, retrying with exponential backoff
+func (d *Dispatcher) process(item delivery) {
+	backoff := d.opts.InitialBackoff
+
+	for {
+		item.Attempts++
+		err := d.deliver(item)
+		if err == nil {
+			return
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || item.Attempts >= d.opts.MaxAttempts {
+			d.writeDeadLetter(item, err)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.stop:
+			d.writeDeadLetter(item, fmt.Errorf("dispatcher stopped after: %w", err))
+			return
+		}
+	}
+}
+
+// permanentError marks failures that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (d *Dispatcher) deliver(item delivery) error {
+	body, err := json.Marshal(item.Event)
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, item.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ShreadBox-Webhook/1.0")
+	req.Header.Set(EventHeader, item.Event.Type)
+	req.Header.Set(SignatureHeader, Sign(item.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	default:
+		return &permanentError{fmt.Errorf("endpoint returned %s", resp.Status)}
+	}
+}
+
+func (d *Dispatcher) writeDeadLetter(item delivery, cause error) {
+	log.Printf("Webhook delivery of %s to %s failed after %d attempts: %v", item.Event.Type, item.URL, item.Attempts, cause)
+
+	if d.opts.DeadLetterPath == "" {
+		return
+	}
+
+	line, err := json.Marshal(deadLetter{
+		URL:      item.URL,
+		Event:    item.Event,
+		Attempts: item.Attempts,
+		Error:    cause.Error(),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return
+	}
+
+	d.deadLetterMu.Lock()
+	defer d.deadLetterMu.Unlock()
+
+	file, err := os.OpenFile(d.opts.DeadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Failed to open webhook dead-letter log: %v", err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+// Sign computes the signature header value for a payload. Receivers should
+// recompute HMAC-SHA256(secret, timestamp + "." + body) and compare it to v1,
+// rejecting stale timestamps to prevent replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateURL checks that a notify URL can receive webhooks
+func ValidateURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// NewSecret generates a per-share signing secret. The secret string itself
+// is the HMAC key, so receivers can use it as given.
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+func newEventID() string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return "evt_" + hex.EncodeToString(id)
+}
+
+// rejectPrivateAddress stops webhooks from reaching internal services
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return fmt.Errorf("webhook destination %s is not allowed", host)
+	}
+	return nil
+}
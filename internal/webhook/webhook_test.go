@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hardiksharma/shreadbox/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDispatcher(t *testing.T, opts Options) *Dispatcher {
+	opts.AllowPrivate = true
+	opts.InitialBackoff = time.Millisecond
+	d := NewDispatcher(opts)
+	d.Start(1)
+	t.Cleanup(func() { d.Stop(context.Background()) })
+	return d
+}
+
+func testEvent(notifyURL, secret string) storage.Event {
+	return storage.Event{
+		Type: storage.EventDownloaded,
+		File: storage.FileMetadata{
+			ID:            "share-1",
+			FileName:      "report.pdf",
+			DownloadsLeft: 0,
+			NotifyURL:     notifyURL,
+			NotifySecret:  secret,
+		},
+		OccurredAt: time.Now(),
+	}
+}
+
+func TestDispatcher_DeliversSignedEvent(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, Options{})
+	d.Listener()(testEvent(server.URL, "whsec_test"))
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		assert.Equal(t, "downloaded", r.Header.Get(EventHeader))
+
+		var event Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, "share-1", event.ShareID)
+		assert.Equal(t, "report.pdf", event.FileName)
+		assert.True(t, strings.HasPrefix(event.ID, "evt_"))
+
+		// The receiver can recompute the signature from the timestamp
+		signature := r.Header.Get(SignatureHeader)
+		parts := strings.SplitN(signature, ",", 2)
+		require.Len(t, parts, 2)
+		var ts int64
+		_, err := fmt.Sscanf(parts[0], "t=%d", &ts)
+		require.NoError(t, err)
+		assert.Equal(t, signature, Sign("whsec_test", time.Unix(ts, 0), body))
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
+
+func TestDispatcher_GlobalURLs(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(t, Options{GlobalURLs: []string{server.URL}, Secret: "global"})
+
+	// Delivered to the global URL and the share's own URL
+	d.Listener()(testEvent(server.URL, "whsec_test"))
+	assert.Eventually(t, func() bool { return hits.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// Shares without a notify URL still reach global URLs
+	d.Listener()(testEvent("", ""))
+	assert.Eventually(t, func() bool { return hits.Load() == 3 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDispatcher_RetriesThenDeadLetters(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	deadLetters := filepath.Join(t.TempDir(), "failed.jsonl")
+	d := newTestDispatcher(t, Options{MaxAttempts: 3, DeadLetterPath: deadLetters})
+	d.Listener()(testEvent(server.URL, "whsec_test"))
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(deadLetters)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), attempts.Load())
+
+	file, err := os.Open(deadLetters)
+	require.NoError(t, err)
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	require.True(t, scanner.Scan())
+	var record deadLetter
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+	assert.Equal(t, server.URL, record.URL)
+	assert.Equal(t, 3, record.Attempts)
+	assert.Equal(t, "share-1", record.Event.ShareID)
+}
+
+func TestDispatcher_ClientErrorsAreNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	deadLetters := filepath.Join(t.TempDir(), "failed.jsonl")
+	d := newTestDispatcher(t, Options{MaxAttempts: 5, DeadLetterPath: deadLetters})
+	d.Listener()(testEvent(server.URL, "whsec_test"))
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(deadLetters)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestDispatcher_RejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private address should not be reached")
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(Options{})
+	err := d.deliver(delivery{URL: server.URL, Event: Event{Type: "downloaded"}})
+	assert.Error(t, err)
+}
+
+func TestValidateURL(t *testing.T) {
+	assert.NoError(t, ValidateURL("https://example.com/hook"))
+	assert.NoError(t, ValidateURL("http://example.com:8080/hook?x=1"))
+	assert.ErrorIs(t, ValidateURL("ftp://example.com"), ErrInvalidURL)
+	assert.ErrorIs(t, ValidateURL("/relative"), ErrInvalidURL)
+	assert.ErrorIs(t, ValidateURL("https://"), ErrInvalidURL)
+}
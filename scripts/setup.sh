@@ -8,8 +8,6 @@ set -x
 
 # Create necessary directories
 mkdir -p storage
-mkdir -p web/static
-mkdir -p web/templates
 
 # Create .env file if it doesn't exist
 if [ ! -f .env ]; then
@@ -22,6 +20,72 @@ GIN_MODE=debug  # Set to 'release' in production
 MAX_FILE_SIZE=10  # Maximum file size in MB
 STORAGE_PATH=./storage
 CLEANUP_INTERVAL=5m  # Format: 1h, 5m, 30s, etc.
+RECONCILE_INTERVAL=1h  # Storage directory consistency check, 0 to run only on start
+SHUTDOWN_TIMEOUT=30s  # Time to drain in-flight transfers on shutdown
+SHUTDOWN_DELAY=0s  # Time /ready reports 503 before shutdown refuses connections
+
+# TLS (enabled when both cert and key are set)
+TLS_CERT_FILE=
+TLS_KEY_FILE=
+TLS_CLIENT_CA_FILE=  # Require client certificates for /admin
+HTTP_REDIRECT_PORT=  # e.g. 80, redirects plain HTTP to HTTPS
+
+# Quotas (0 = unlimited)
+CLIENT_QUOTA=0       # Stored MB per client
+CLIENT_MAX_SHARES=0  # Active shares per client
+STORAGE_CAPACITY=0   # Stored MB across all clients
+TRUSTED_PROXIES=     # Comma separated proxy IPs/CIDRs allowed to set X-Forwarded-For
+
+# Authentication
+API_KEYS_FILE=./apikeys.json
+AUTH_REQUIRED=false  # Reject uploads without an API key
+ADMIN_TOKEN=  # Bearer token for /admin when mTLS is not configured
+
+# Upload type policy (comma separated, deny wins, empty allow = allow all)
+ALLOWED_TYPES=       # e.g. application/pdf,image/*
+DENIED_TYPES=        # e.g. text/html,image/svg+xml
+ALLOWED_EXTENSIONS=
+DENIED_EXTENSIONS=   # e.g. .exe,.scr
+
+# Malware scanning
+SCANNER=             # clamd, command or empty to disable
+CLAMD_ADDRESS=unix:/var/run/clamav/clamd.ctl
+SCAN_COMMAND=clamscan --no-summary -
+SCAN_TIMEOUT=2m
+
+# Webhooks
+WEBHOOK_URLS=        # Comma separated URLs receiving events for every share
+WEBHOOK_SECRET=      # HMAC secret signing deliveries to WEBHOOK_URLS, required with them
+WEBHOOK_MAX_ATTEMPTS=5
+WEBHOOK_DEAD_LETTER_FILE=./webhooks-failed.jsonl
+WEBHOOK_ALLOW_PRIVATE=false  # Allow deliveries to loopback/private addresses
+
+# Email notifications (disabled when SMTP_HOST is empty)
+SMTP_HOST=
+SMTP_PORT=587
+SMTP_USERNAME=
+SMTP_PASSWORD=
+SMTP_FROM=ShreadBox <noreply@localhost>
+BASE_URL=http://localhost:8080  # Public URL used for links in emails and QR codes
+
+# Download confirmation pages
+LINK_SECRET=         # Signs confirmation pages, random per process if empty
+
+# Storage efficiency
+DEDUP_SECRET=        # Enables dedup=true uploads, identical files share one blob
+COMPRESSION=none     # none, gzip or zstd, applied before encryption when it helps
+
+# Integrity digests (SHA-256 is always computed)
+DIGEST_BLAKE3=false  # Also compute BLAKE3 digests of uploads
+
+# Share tokens
+TOKEN_FORMAT=uuid          # uuid, base58 or words (e.g. 7-apple-river)
+TOKEN_WORDS=3              # Words in word codes
+TOKEN_MAX_FAILURES=10      # Unknown tokens per client before it is blocked, 0 = unlimited
+TOKEN_FAILURE_WINDOW=15m
+
+# Errors
+OPAQUE_ERRORS=false  # Report expired/exhausted, refused and pending shares as 404
 
 # Rate Limiting
 RATE_LIMIT=100  # Requests per minute
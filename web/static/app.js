@@ -0,0 +1,107 @@
+// Helpers shared by the ShreadBox pages
+'use strict';
+
+const ShreadBox = (() => {
+    const storageKey = 'shreadbox.shares';
+
+    // Shares uploaded from this browser, with the management tokens that
+    // check and revoke them. Nothing here ever leaves the browser except
+    // in requests to this server.
+    const shares = {
+        list() {
+            try {
+                const list = JSON.parse(localStorage.getItem(storageKey) || '[]');
+                return Array.isArray(list) ? list : [];
+            } catch {
+                return [];
+            }
+        },
+        save(list) {
+            localStorage.setItem(storageKey, JSON.stringify(list));
+        },
+        add(share) {
+            this.save([share, ...this.list().filter((s) => s.id !== share.id)]);
+        },
+        remove(id) {
+            this.save(this.list().filter((s) => s.id !== id));
+        },
+    };
+
+    // problem returns the message of a failed API response, which is
+    // application/problem+json for every API error
+    async function problem(response) {
+        try {
+            const body = await response.json();
+            return body.detail || body.title || response.statusText;
+        } catch {
+            return response.statusText || 'Request failed with status ' + response.status;
+        }
+    }
+
+    async function copy(text, button) {
+        try {
+            await navigator.clipboard.writeText(text);
+        } catch {
+            // Clipboard access needs a secure context, fall back to selecting
+            const input = document.createElement('textarea');
+            input.value = text;
+            document.body.appendChild(input);
+            input.select();
+            document.execCommand('copy');
+            input.remove();
+        }
+        if (button) {
+            const label = button.textContent;
+            button.textContent = 'Copied';
+            setTimeout(() => { button.textContent = label; }, 1500);
+        }
+    }
+
+    function formatSize(bytes) {
+        if (bytes < 1024) {
+            return bytes + ' B';
+        }
+        const units = 'KMGTPE';
+        let size = bytes / 1024;
+        let unit = 0;
+        while (size >= 1024 && unit < units.length - 1) {
+            size /= 1024;
+            unit++;
+        }
+        return size.toFixed(1) + ' ' + units[unit] + 'B';
+    }
+
+    function formatDate(value) {
+        return new Date(value).toLocaleString(undefined, { dateStyle: 'medium', timeStyle: 'short' });
+    }
+
+    function plural(count, word) {
+        return count + ' ' + word + (count === 1 ? '' : 's');
+    }
+
+    function linkFor(token) {
+        return location.origin + '/d/' + encodeURIComponent(token);
+    }
+
+    function qrFor(token) {
+        return '/api/qr/' + encodeURIComponent(token) + '?format=svg';
+    }
+
+    // Buttons declared in the templates
+    document.addEventListener('click', (event) => {
+        const button = event.target.closest('[data-copy], [data-copy-location], [data-toggle]');
+        if (!button) {
+            return;
+        }
+        if (button.dataset.copy) {
+            copy(document.getElementById(button.dataset.copy).value, button);
+        } else if (button.hasAttribute('data-copy-location')) {
+            copy(location.origin + location.pathname, button);
+        } else {
+            const target = document.getElementById(button.dataset.toggle);
+            target.hidden = !target.hidden;
+        }
+    });
+
+    return { shares, problem, copy, formatSize, formatDate, plural, linkFor, qrFor };
+})();
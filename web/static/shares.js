@@ -0,0 +1,149 @@
+// My shares page: follows and revokes the shares uploaded from this
+// browser with their management tokens
+'use strict';
+
+(() => {
+    const list = document.getElementById('shares');
+    const template = document.getElementById('share-template');
+    const forgetEnded = document.getElementById('forget-ended');
+
+    const scanStates = {
+        pending: 'Being scanned',
+        infected: 'Destroyed by the malware scan',
+        failed: 'Destroyed, the scan failed',
+    };
+
+    function field(item, name) {
+        return item.querySelector('[data-field="' + name + '"]');
+    }
+
+    function setState(item, label, kind) {
+        const badge = field(item, 'state');
+        badge.textContent = label;
+        badge.className = 'badge ' + kind;
+    }
+
+    // ended marks a share that no longer exists on the server
+    function ended(item) {
+        item.classList.add('ended');
+        setState(item, 'Ended', 'muted');
+        field(item, 'facts').textContent = 'Expired, used up or revoked. The file has been shredded.';
+        item.querySelector('[data-action="revoke"]').hidden = true;
+        forgetEnded.hidden = false;
+    }
+
+    function manage(share, method) {
+        return fetch('/api/shares/' + encodeURIComponent(share.id), {
+            method: method,
+            headers: { 'X-Management-Token': share.management_token || '' },
+        });
+    }
+
+    async function refresh(item, share) {
+        let response;
+        try {
+            response = await manage(share, 'GET');
+        } catch {
+            setState(item, 'Offline', 'muted');
+            return;
+        }
+        if (response.status === 404) {
+            ended(item);
+            return;
+        }
+        if (!response.ok) {
+            setState(item, 'Unknown', 'muted');
+            field(item, 'facts').textContent = await ShreadBox.problem(response);
+            return;
+        }
+
+        const status = await response.json();
+        const facts = [
+            ShreadBox.formatSize(status.file_size),
+            ShreadBox.plural(status.downloads_left, 'download') + ' left',
+            ShreadBox.plural(status.download_count, 'download') + ' so far',
+            'expires ' + ShreadBox.formatDate(status.expires_at),
+        ];
+        if (status.previews_left !== undefined) {
+            facts.splice(2, 0, ShreadBox.plural(status.previews_left, 'preview') + ' left');
+        }
+        if (status.access_denials && status.access_denials.length > 0) {
+            facts.push(ShreadBox.plural(status.access_denials.length, 'refused attempt'));
+        }
+        field(item, 'facts').textContent = facts.join(' · ');
+
+        if (scanStates[status.scan_status]) {
+            setState(item, scanStates[status.scan_status], status.scan_status === 'pending' ? 'warning' : 'error');
+        } else if (status.downloads_left <= 0) {
+            ended(item);
+        } else {
+            setState(item, 'Active', 'success');
+        }
+    }
+
+    function render(share) {
+        const item = template.content.firstElementChild.cloneNode(true);
+        item.dataset.id = share.id;
+        field(item, 'name').textContent = share.file_name;
+        field(item, 'link').value = share.link || ShreadBox.linkFor(share.token);
+        field(item, 'facts').textContent = 'Expires ' + ShreadBox.formatDate(share.expires_at);
+
+        item.addEventListener('click', async (event) => {
+            const button = event.target.closest('[data-action]');
+            if (!button) {
+                return;
+            }
+            switch (button.dataset.action) {
+            case 'copy':
+                ShreadBox.copy(field(item, 'link').value, button);
+                break;
+            case 'qr': {
+                const qr = field(item, 'qr');
+                if (!qr.src) {
+                    qr.src = ShreadBox.qrFor(share.token);
+                }
+                qr.hidden = !qr.hidden;
+                break;
+            }
+            case 'revoke': {
+                if (!confirm('Revoke ' + share.file_name + '? The file is shredded and the link stops working.')) {
+                    return;
+                }
+                const response = await manage(share, 'DELETE');
+                if (response.ok || response.status === 404) {
+                    ended(item);
+                } else {
+                    field(item, 'facts').textContent = await ShreadBox.problem(response);
+                }
+                break;
+            }
+            case 'forget':
+                ShreadBox.shares.remove(share.id);
+                item.remove();
+                showEmpty();
+                break;
+            }
+        });
+
+        list.appendChild(item);
+        refresh(item, share);
+    }
+
+    function showEmpty() {
+        document.getElementById('shares-empty').hidden = list.children.length > 0;
+        forgetEnded.hidden = !list.querySelector('.ended');
+    }
+
+    forgetEnded.addEventListener('click', () => {
+        const ids = new Set();
+        list.querySelectorAll('.ended').forEach((item) => {
+            ids.add(item.dataset.id);
+            item.remove();
+        });
+        ShreadBox.shares.save(ShreadBox.shares.list().filter((s) => !ids.has(s.id)));
+        showEmpty();
+    });
+
+    ShreadBox.shares.list().forEach(render);
+    showEmpty();
+})();
@@ -0,0 +1,96 @@
+// Status page: reports on a share by its link or token, taken from the
+// form or the URL fragment so pages can link here without sending the
+// token to the server in a URL
+'use strict';
+
+(() => {
+    const form = document.getElementById('status-form');
+    const input = document.getElementById('status-token');
+    const errorBox = document.getElementById('status-error');
+    const result = document.getElementById('status-result');
+
+    const scanStates = {
+        pending: 'Being checked for malware',
+        infected: 'Destroyed by the malware scan',
+        failed: 'Destroyed, the malware scan failed',
+    };
+
+    // tokenFrom accepts a pasted link as well as a bare token
+    function tokenFrom(value) {
+        value = value.trim();
+        try {
+            const url = new URL(value);
+            const parts = url.pathname.split('/').filter(Boolean);
+            return decodeURIComponent(parts[parts.length - 1] || '');
+        } catch {
+            return value;
+        }
+    }
+
+    function showError(message) {
+        errorBox.textContent = message;
+        errorBox.hidden = !message;
+        if (message) {
+            result.hidden = true;
+        }
+    }
+
+    function text(id, value) {
+        document.getElementById(id).textContent = value;
+    }
+
+    async function check(token) {
+        showError('');
+        if (!token) {
+            showError('Paste a link or token to check.');
+            return;
+        }
+        history.replaceState(null, '', '#' + encodeURIComponent(token));
+
+        let response;
+        try {
+            response = await fetch('/api/status/' + encodeURIComponent(token));
+        } catch {
+            showError('The server could not be reached.');
+            return;
+        }
+        if (response.status === 404) {
+            showError('This link has expired or does not exist.');
+            return;
+        }
+        if (!response.ok) {
+            showError(await ShreadBox.problem(response));
+            return;
+        }
+
+        const status = await response.json();
+        text('status-file', status.file_name);
+        if (scanStates[status.scan_status]) {
+            text('status-state', scanStates[status.scan_status]);
+        } else if (status.downloads_left > 0) {
+            text('status-state', status.sealed ? 'Available, sealed to the recipient\'s key' : 'Available');
+        } else {
+            text('status-state', 'Used up');
+        }
+        text('status-expires', ShreadBox.formatDate(status.expires_at));
+        text('status-downloads', status.downloads_left);
+        text('status-previews', status.previews_left === undefined ? '' : status.previews_left);
+        result.querySelectorAll('.optional').forEach((el) => { el.hidden = status.previews_left === undefined; });
+
+        const message = document.getElementById('status-message');
+        message.textContent = status.message || '';
+        message.hidden = !status.message;
+        document.getElementById('status-open').href = '/d/' + encodeURIComponent(token);
+        result.hidden = false;
+    }
+
+    form.addEventListener('submit', (event) => {
+        event.preventDefault();
+        check(tokenFrom(input.value));
+    });
+
+    if (location.hash.length > 1) {
+        input.value = decodeURIComponent(location.hash.slice(1));
+        check(input.value);
+    }
+})();
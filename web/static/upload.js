@@ -0,0 +1,194 @@
+// Upload page: drag and drop, progress and cancel, and the share link
+'use strict';
+
+(() => {
+    const form = document.getElementById('upload-form');
+    const fileInput = document.getElementById('file');
+    const dropzone = document.getElementById('dropzone');
+    const progress = document.getElementById('progress');
+    const progressFill = document.getElementById('progress-fill');
+    const progressText = document.getElementById('progress-text');
+    const cancelButton = document.getElementById('cancel');
+    const submitButton = document.getElementById('submit');
+    const errorBox = document.getElementById('error');
+    const result = document.getElementById('result');
+    const timezone = document.getElementById('access-timezone');
+
+    // Digests are computed in the browser only up to this size, as the
+    // whole file has to be read into memory
+    const maxDigestSize = 512 * 1024 * 1024;
+
+    let request = null;
+    let cancelled = false;
+
+    timezone.placeholder = Intl.DateTimeFormat().resolvedOptions().timeZone || 'UTC';
+
+    function showFile() {
+        const file = fileInput.files[0];
+        document.getElementById('file-name').textContent = file ? file.name : 'Drop a file here or click to choose one';
+        document.getElementById('file-size').textContent = file ? ShreadBox.formatSize(file.size) : '';
+        dropzone.classList.toggle('chosen', Boolean(file));
+    }
+
+    function showError(message) {
+        errorBox.textContent = message;
+        errorBox.hidden = !message;
+    }
+
+    function setBusy(busy) {
+        form.classList.toggle('busy', busy);
+        submitButton.disabled = busy;
+        progress.hidden = !busy;
+        if (!busy) {
+            progressFill.style.width = '0';
+        }
+    }
+
+    fileInput.addEventListener('change', showFile);
+
+    ['dragenter', 'dragover'].forEach((type) => dropzone.addEventListener(type, (event) => {
+        event.preventDefault();
+        dropzone.classList.add('dragging');
+    }));
+    ['dragleave', 'drop'].forEach((type) => dropzone.addEventListener(type, () => {
+        dropzone.classList.remove('dragging');
+    }));
+    dropzone.addEventListener('drop', (event) => {
+        event.preventDefault();
+        if (event.dataTransfer.files.length > 0) {
+            fileInput.files = event.dataTransfer.files;
+            showFile();
+        }
+    });
+
+    cancelButton.addEventListener('click', () => {
+        cancelled = true;
+        if (request) {
+            request.abort();
+        }
+    });
+
+    async function sha256(file) {
+        const digest = await crypto.subtle.digest('SHA-256', await file.arrayBuffer());
+        return Array.from(new Uint8Array(digest), (b) => b.toString(16).padStart(2, '0')).join('');
+    }
+
+    // buildForm returns the form's fields, leaving out empty ones so the
+    // server applies its defaults
+    function buildForm() {
+        const data = new FormData(form);
+        for (const [name, value] of Array.from(data.entries())) {
+            if (typeof value === 'string' && value.trim() === '') {
+                data.delete(name);
+            }
+        }
+
+        const notBefore = document.getElementById('not-before').value;
+        if (notBefore) {
+            data.set('not_before', new Date(notBefore).toISOString());
+        }
+        if (data.has('access_windows') && !data.has('access_timezone')) {
+            data.set('access_timezone', timezone.placeholder);
+        }
+        return data;
+    }
+
+    function send(data) {
+        return new Promise((resolve, reject) => {
+            request = new XMLHttpRequest();
+            request.open('POST', '/api/upload');
+            const apiKey = document.getElementById('api-key').value.trim();
+            if (apiKey) {
+                request.setRequestHeader('Authorization', 'Bearer ' + apiKey);
+            }
+
+            request.upload.addEventListener('progress', (event) => {
+                if (!event.lengthComputable) {
+                    return;
+                }
+                const percent = Math.floor(event.loaded / event.total * 100);
+                progressFill.style.width = percent + '%';
+                progressText.textContent = percent < 100
+                    ? 'Uploading… ' + percent + '% of ' + ShreadBox.formatSize(event.total)
+                    : 'Encrypting…';
+            });
+            request.addEventListener('load', () => resolve(new Response(request.response, {
+                status: request.status,
+                statusText: request.statusText,
+                headers: { 'Content-Type': request.getResponseHeader('Content-Type') || '' },
+            })));
+            request.addEventListener('error', () => reject(new Error('The upload failed, check your connection.')));
+            request.addEventListener('abort', () => reject(new Error('Upload cancelled.')));
+            request.send(data);
+        });
+    }
+
+    form.addEventListener('submit', async (event) => {
+        event.preventDefault();
+        showError('');
+
+        const file = fileInput.files[0];
+        if (!file) {
+            showError('Choose a file to share first.');
+            return;
+        }
+
+        cancelled = false;
+        setBusy(true);
+        try {
+            const data = buildForm();
+            const verify = document.getElementById('verify').checked;
+            if (verify && window.crypto && crypto.subtle && file.size <= maxDigestSize) {
+                progressText.textContent = 'Computing checksum…';
+                data.set('expected_digest', await sha256(file));
+            }
+            if (cancelled) {
+                throw new Error('Upload cancelled.');
+            }
+
+            const response = await send(data);
+            if (!response.ok) {
+                throw new Error(await ShreadBox.problem(response));
+            }
+            showResult(await response.json());
+        } catch (error) {
+            showError(error.message);
+        } finally {
+            request = null;
+            setBusy(false);
+        }
+    });
+
+    function showResult(share) {
+        const link = location.origin + share.download_url;
+        ShreadBox.shares.add({
+            id: share.id,
+            token: share.token,
+            management_token: share.management_token,
+            file_name: share.file_name,
+            link: link,
+            expires_at: share.expires_at,
+            created_at: new Date().toISOString(),
+        });
+
+        document.getElementById('share-link').value = link;
+        document.getElementById('share-qr').src = ShreadBox.qrFor(share.token);
+        document.getElementById('result-file').textContent = share.file_name;
+        document.getElementById('result-expires').textContent = ShreadBox.formatDate(share.expires_at);
+        document.getElementById('result-downloads').textContent = form.elements.downloads_allowed.value || '1';
+        document.getElementById('result-previews').textContent = share.previews_allowed || 'None';
+        document.getElementById('result-digest').textContent = (share.digests && share.digests['sha-256']) || '';
+        document.getElementById('result-secret-value').textContent = share.notify_secret || '';
+        document.getElementById('result-secret').hidden = !share.notify_secret;
+
+        document.getElementById('upload').hidden = true;
+        result.hidden = false;
+    }
+
+    document.getElementById('another').addEventListener('click', () => {
+        form.reset();
+        showFile();
+        result.hidden = true;
+        document.getElementById('upload').hidden = false;
+    });
+})();
@@ -0,0 +1,27 @@
+// Package web holds the browser interface. Templates and assets are
+// embedded, so the binary serves them from any working directory.
+package web
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed templates static
+var files embed.FS
+
+// Templates parses the page templates, named by file name
+func Templates() (*template.Template, error) {
+	return template.ParseFS(files, "templates/*.html")
+}
+
+// Static returns the stylesheet, scripts and images served under /static
+func Static() http.FileSystem {
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		panic("embedded static directory missing: " + err.Error())
+	}
+	return http.FS(static)
+}
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := Templates()
+	require.NoError(t, err)
+
+	for _, name := range []string{"index.html", "download.html", "shares.html", "status.html", "header", "footer"} {
+		assert.NotNil(t, templates.Lookup(name), name)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	static := Static()
+
+	// Every script and stylesheet the templates load is embedded
+	for _, name := range []string{"app.css", "app.js", "upload.js", "shares.js", "status.js"} {
+		file, err := static.Open(name)
+		require.NoError(t, err, name)
+		data, err := io.ReadAll(file)
+		file.Close()
+		require.NoError(t, err)
+		assert.NotEmpty(t, data, name)
+	}
+
+	_, err := static.Open("../templates/index.html")
+	assert.Error(t, err, "templates are not served as static files")
+}